@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"text/template"
 
@@ -53,11 +55,35 @@ type ModuleDetails struct {
 // Get API tokens for libraries.io
 var librariesio_token = os.Getenv("LIBRARIESIO_TOKEN")
 
+// Parsers of the 12 attack vectors of the GoSurf paper, which the reports count
+var paperParsers = []analysis.OccurrenceParser{
+	analysis.InitFuncParser{},
+	analysis.GlobalVarParser{},
+	analysis.ExecParser{},
+	analysis.PluginParser{},
+	analysis.GoGenerateParser{},
+	analysis.GoTestParser{},
+	analysis.UnsafeParser{},
+	analysis.CgoParser{},
+	analysis.InterfaceParser{},
+	analysis.ReflectParser{},
+	analysis.ConstructorParser{},
+	analysis.AssemblyParser{},
+}
+
+// Number of packages analyzed in parallel
+var workers = flag.Int("workers", runtime.NumCPU(), "number of packages analyzed in parallel")
+
+func init() {
+	flag.IntVar(workers, "j", runtime.NumCPU(), "shorthand for -workers")
+}
+
 func main() {
 
+	flag.Parse()
 	expName := "exp1"
-	if len(os.Args) > 1 {
-		expName = os.Args[1]
+	if flag.NArg() > 0 {
+		expName = flag.Arg(0)
 	}
 	if expName != "exp1" && expName != "exp2" {
 		fmt.Println("Invalid input. Please provide 'exp1' or 'exp2'.")
@@ -154,42 +180,17 @@ func main() {
 			continue
 		}
 
-		// Analyze the module and its direct dependencies
-		var initOccurrences, globalVarOccurrences, execOccurrences, pluginOccurrences, goGenerateOccurrences, goTestOccurrences, unsafeOccurrences, cgoOccurrences, interfaceOccurrences, reflectOccurrences, constructorOccurrences, assemblyOccurrences []*analysis.Occurrence
-		direct_dependencies, err := analysis.GetDependencies(modulePath)
+		// Analyze the module and all the modules it requires, as the gosurf command does
+		dependencies, missingModules, err := analysis.GetModuleDependencies(modulePath)
 		if err != nil {
-			fmt.Printf("Error getting files in module: %v\n", err)
+			fmt.Printf("Error getting module dependencies: %v\n", err)
 			return
 		}
-
-		for _, dep := range direct_dependencies {
-			analysis.AnalyzePackage(dep, &initOccurrences, analysis.InitFuncParser{})
-			analysis.AnalyzePackage(dep, &globalVarOccurrences, analysis.GlobalVarParser{})
-			analysis.AnalyzePackage(dep, &execOccurrences, analysis.ExecParser{})
-			analysis.AnalyzePackage(dep, &pluginOccurrences, analysis.PluginParser{})
-			analysis.AnalyzePackage(dep, &goGenerateOccurrences, analysis.GoGenerateParser{})
-			analysis.AnalyzePackage(dep, &goTestOccurrences, analysis.GoTestParser{})
-			analysis.AnalyzePackage(dep, &unsafeOccurrences, analysis.UnsafeParser{})
-			analysis.AnalyzePackage(dep, &cgoOccurrences, analysis.CgoParser{})
-			analysis.AnalyzePackage(dep, &interfaceOccurrences, analysis.InterfaceParser{})
-			analysis.AnalyzePackage(dep, &reflectOccurrences, analysis.ReflectParser{})
-			analysis.AnalyzePackage(dep, &constructorOccurrences, analysis.ConstructorParser{})
-			analysis.AnalyzePackage(dep, &assemblyOccurrences, analysis.AssemblyParser{})
+		for _, mod := range missingModules {
+			fmt.Printf("Module %s@%s not found in vendor/ or module cache, skipping\n", mod.Path, mod.Version)
 		}
 
-		occurrences := append(append(append(append(append(append(append(append(append(append(append(
-			initOccurrences,
-			globalVarOccurrences...),
-			execOccurrences...),
-			pluginOccurrences...),
-			goGenerateOccurrences...),
-			goTestOccurrences...),
-			unsafeOccurrences...),
-			cgoOccurrences...),
-			interfaceOccurrences...),
-			reflectOccurrences...),
-			constructorOccurrences...),
-			assemblyOccurrences...)
+		occurrences := analysis.AnalyzeDependencies(dependencies, *workers, paperParsers...)
 
 		initCount, globalVarCount, execCount, pluginCount, goGenerateCount, goTestCount, unsafeCount, cgoCount, interfaceCount, reflectCount, constructorCount, assemblyCount := analysis.CountUniqueOccurrences(occurrences)
 
@@ -210,18 +211,18 @@ func main() {
 			ReflectCount:           []float64{float64(reflectCount), float64(reflectCount) / float64(locCount)},
 			ConstructorCount:       []float64{float64(constructorCount), float64(constructorCount) / float64(locCount)},
 			AssemblyCount:          []float64{float64(assemblyCount), float64(assemblyCount) / float64(locCount)},
-			InitOccurrences:        initOccurrences,
-			GlobalVarOccurrences:   globalVarOccurrences,
-			ExecOccurrences:        execOccurrences,
-			PluginOccurrences:      pluginOccurrences,
-			GoGenerateOccurrences:  goGenerateOccurrences,
-			GoTestOccurrences:      goTestOccurrences,
-			UnsafeOccurrences:      unsafeOccurrences,
-			CgoOccurrences:         cgoOccurrences,
-			InterfaceOccurrences:   interfaceOccurrences,
-			ReflectOccurrences:     reflectOccurrences,
-			ConstructorOccurrences: constructorOccurrences,
-			AssemblyOccurrences:    assemblyOccurrences,
+			InitOccurrences:        analysis.FilterOccurrences(occurrences, "init"),
+			GlobalVarOccurrences:   analysis.FilterOccurrences(occurrences, "global"),
+			ExecOccurrences:        analysis.FilterOccurrences(occurrences, "exec"),
+			PluginOccurrences:      analysis.FilterOccurrences(occurrences, "plugin"),
+			GoGenerateOccurrences:  analysis.FilterOccurrences(occurrences, "generate"),
+			GoTestOccurrences:      analysis.FilterOccurrences(occurrences, "test"),
+			UnsafeOccurrences:      analysis.FilterOccurrences(occurrences, "unsafe"),
+			CgoOccurrences:         analysis.FilterOccurrences(occurrences, "cgo"),
+			InterfaceOccurrences:   analysis.FilterOccurrences(occurrences, "interface"),
+			ReflectOccurrences:     analysis.FilterOccurrences(occurrences, "reflect"),
+			ConstructorOccurrences: analysis.FilterOccurrences(occurrences, "constructor"),
+			AssemblyOccurrences:    analysis.FilterOccurrences(occurrences, "assembly"),
 		}
 		allModules[idx] = moduleDetails
 	}
@@ -1,38 +1,19 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 
 	analysis "example.com/gosurf/libs"
 )
 
-var (
-	initOccurrences        []*analysis.Occurrence
-	globalVarOccurrences   []*analysis.Occurrence
-	execOccurrences        []*analysis.Occurrence
-	pluginOccurrences      []*analysis.Occurrence
-	goGenerateOccurrences  []*analysis.Occurrence
-	goTestOccurrences      []*analysis.Occurrence
-	unsafeOccurrences      []*analysis.Occurrence
-	cgoOccurrences         []*analysis.Occurrence
-	interfaceOccurrences   []*analysis.Occurrence
-	reflectOccurrences     []*analysis.Occurrence
-	constructorOccurrences []*analysis.Occurrence
-	assemblyOccurrences    []*analysis.Occurrence
-)
-
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run main.go <module_path>")
-		return
-	}
-
-	modulePath := os.Args[1]
-
-	asciiArt := `
+var asciiArt = `
                                                                                                                
   ,ad8888ba,                 ad88888ba                               ad88                                      
  d8"'    '"8b               d8"     "8b                             d8"                                        
@@ -45,75 +26,608 @@ Y8,        88  8b       d8          '8b  88       88  88            88     ,adPP
                                                                                                                
                                                                                                           "
 `
-	fmt.Println(asciiArt)
 
-	fmt.Println("GoSurf is a tool that aims to analyze the potential attack surface of open-source Go packages and modules.")
-	fmt.Println("It looks for occurrences of various features and constructs that could potentially introduce security risks.")
-	fmt.Println()
+type options struct {
+	workers       int
+	reachableOnly bool
+	callGraph     bool
+	rootPackages  string
+	baselinePath  string
+	goos          string
+	goarch        string
+	tags          string
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			runCheck(os.Args[2:])
+			return
+		case "baseline":
+			runBaseline(os.Args[2:])
+			return
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "files":
+			runFiles(os.Args[2:])
+			return
+		case "lock":
+			runLock(os.Args[2:])
+			return
+		}
+	}
+	runAnalyze(os.Args[1:])
+}
+
+// Registers the flags controlling the analysis, shared by all the commands.
+func addAnalysisFlags(fs *flag.FlagSet, opts *options) {
+	fs.BoolVar(&opts.reachableOnly, "reachable", false, "only report packages reachable from the main packages of the module through imports")
+	fs.BoolVar(&opts.callGraph, "callgraph", false, "mark occurrences reachable from the exported API and main functions of the module (or of -pkgs) through the call graph; with -reachable, only report them")
+	fs.StringVar(&opts.rootPackages, "pkgs", "", "comma-separated import paths (or path/... patterns) to start the reachability analysis from, implies -reachable")
+	fs.IntVar(&opts.workers, "workers", runtime.NumCPU(), "number of packages analyzed in parallel")
+	fs.IntVar(&opts.workers, "j", runtime.NumCPU(), "shorthand for -workers")
+	addBuildFlags(fs, opts)
+}
 
-	// TODO: currently only fetches direct dependencies in the module, not external dependencies
+// Registers the flags selecting files by build constraints, as the go tool does.
+func addBuildFlags(fs *flag.FlagSet, opts *options) {
+	fs.StringVar(&opts.goos, "goos", "", "only analyze files built for this GOOS (default: all files)")
+	fs.StringVar(&opts.goarch, "goarch", "", "only analyze files built for this GOARCH (default: all files)")
+	fs.StringVar(&opts.tags, "tags", "", "comma-separated build tags satisfied when selecting files, implies file selection")
+}
 
-	// Get direct dependencies
-	direct_dependencies, err := analysis.GetDependencies(modulePath)
-	if err != nil {
-		fmt.Printf("Error getting files in module: %v\n", err)
+// Sets the build context of the analysis if any of -goos, -goarch or -tags is given.
+func applyBuildFlags(opts options) {
+	if opts.goos == "" && opts.goarch == "" && opts.tags == "" {
 		return
 	}
-	// analysis.PrintDependencies(direct_dependencies)
-
-	// Analyze all the module direct dependencies
-	for _, dep := range direct_dependencies {
-		analysis.AnalyzePackage(dep, &initOccurrences, analysis.InitFuncParser{})
-		analysis.AnalyzePackage(dep, &globalVarOccurrences, analysis.GlobalVarParser{})
-		analysis.AnalyzePackage(dep, &execOccurrences, analysis.ExecParser{})
-		analysis.AnalyzePackage(dep, &pluginOccurrences, analysis.PluginParser{})
-		analysis.AnalyzePackage(dep, &goGenerateOccurrences, analysis.GoGenerateParser{})
-		analysis.AnalyzePackage(dep, &goTestOccurrences, analysis.GoTestParser{})
-		analysis.AnalyzePackage(dep, &unsafeOccurrences, analysis.UnsafeParser{})
-		analysis.AnalyzePackage(dep, &cgoOccurrences, analysis.CgoParser{})
-		analysis.AnalyzePackage(dep, &interfaceOccurrences, analysis.InterfaceParser{})
-		analysis.AnalyzePackage(dep, &reflectOccurrences, analysis.ReflectParser{})
-		analysis.AnalyzePackage(dep, &constructorOccurrences, analysis.ConstructorParser{})
-		analysis.AnalyzePackage(dep, &assemblyOccurrences, analysis.AssemblyParser{})
-	}
-
-	// Convert occurrences to JSON
-	occurrences := append(append(append(append(append(append(append(append(append(append(append(
-		initOccurrences,
-		globalVarOccurrences...),
-		execOccurrences...),
-		pluginOccurrences...),
-		goGenerateOccurrences...),
-		goTestOccurrences...),
-		unsafeOccurrences...),
-		cgoOccurrences...),
-		interfaceOccurrences...),
-		reflectOccurrences...),
-		constructorOccurrences...),
-		assemblyOccurrences...)
+	var tags []string
+	if opts.tags != "" {
+		tags = strings.Split(opts.tags, ",")
+	}
+	analysis.BuildContext = analysis.NewBuildContext(opts.goos, opts.goarch, tags)
+}
+
+// Analyzes a module and prints the attack surface report.
+func runAnalyze(args []string) {
+	var opts options
+	fs := flag.NewFlagSet("gosurf", flag.ExitOnError)
+	addAnalysisFlags(fs, &opts)
+	fs.StringVar(&opts.baselinePath, "baseline", "", "only report occurrences that are not recorded in this baseline file")
+	format := fs.String("format", "text", "output format: text, json or sarif")
+	output := fs.String("output", "", "write the report to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Println("Usage: gosurf [flags] <module_path>")
+		fmt.Println("       gosurf check -policy <policy.json> [flags] <module_path>")
+		fmt.Println("       gosurf baseline write [-output baseline.json] [flags] <module_path>")
+		fmt.Println("       gosurf diff [flags] <old_module> <new_module>")
+		fmt.Println("       gosurf files [-format text|json] [-goos os] [-goarch arch] [-tags tags] <module_path>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		return
+	}
+	if *format != "text" && *format != "json" && *format != "sarif" {
+		fmt.Fprintf(os.Stderr, "Unknown output format %q, expected text, json or sarif\n", *format)
+		os.Exit(2)
+	}
+
+	modulePath := fs.Arg(0)
+
+	if *format == "text" {
+		fmt.Println(asciiArt)
+
+		fmt.Println("GoSurf is a tool that aims to analyze the potential attack surface of open-source Go packages and modules.")
+		fmt.Println("It looks for occurrences of various features and constructs that could potentially introduce security risks.")
+		fmt.Println()
+	}
+
+	occurrences, note, err := analyzeModule(modulePath, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error analyzing module: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Print occurrences
-	// analysis.PrintOccurrences(assemblyOccurrences)
+	// analysis.PrintOccurrences(analysis.FilterOccurrences(occurrences, "assembly"))
 	// analysis.PrintOccurrences(occurrences)
 
-	// Count unique occurrences
-	initCount, globalVarCount, execCount, pluginCount, goGenerateCount, goTestCount, unsafeCount, cgoCount, interfaceCount, reflectCount, constructorCount, assemblyCount := analysis.CountUniqueOccurrences(occurrences)
-	fmt.Println()
-	fmt.Println()
-	fmt.Println("╔═════════════════════════════════════════════════════════════════════════╗")
-	fmt.Printf("║ Attack Surface Analysis: %s	     		         ║\n", filepath.Base(strings.TrimSuffix(modulePath, "/"+filepath.Base(modulePath))))
-	fmt.Println("╠═════════════════════════════════════════════════════════════════════════╣")
-	fmt.Printf("║ [P1] Static Code Generation:                                 %10d ║\n", goGenerateCount)
-	fmt.Printf("║ [P2] Testing Functions:                                      %10d ║\n", goTestCount)
-	fmt.Printf("║ [I1] Global Variable Initialization:                         %10d ║\n", globalVarCount)
-	fmt.Printf("║ [I2] init() Functions:                                       %10d ║\n", initCount)
-	fmt.Printf("║ [E1] Constructor Methods:                                    %10d ║\n", constructorCount)
-	fmt.Printf("║ [E2] Reflection:                                             %10d ║\n", reflectCount)
-	fmt.Printf("║ [E3] Interfaces:                                             %10d ║\n", interfaceCount) // TODO: define better
-	fmt.Printf("║ [E4] Unsafe Pointers:                                        %10d ║\n", unsafeCount)
-	fmt.Printf("║ [E5] CGO Functions:                                          %10d ║\n", cgoCount)
-	fmt.Printf("║ [E6] Assembly Functions:                                     %10d ║\n", assemblyCount) // TODO: define better
-	fmt.Printf("║ [E7] Dynamic Plugins:                                        %10d ║\n", pluginCount)
-	fmt.Printf("║ [E8] External Execution:                                     %10d ║\n", execCount)
-	fmt.Println("╚═════════════════════════════════════════════════════════════════════════╝")
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	switch *format {
+	case "json":
+		report := analysis.NewReport(analysis.GetModuleInfo(modulePath), occurrences)
+		if err := analysis.WriteJSONReport(out, report); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing JSON report: %v\n", err)
+			os.Exit(1)
+		}
+	case "sarif":
+		if err := analysis.WriteSARIF(out, modulePath, occurrences); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing SARIF log: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		printSummary(out, modulePath, note, occurrences)
+	}
+}
+
+// Manages baseline files. "baseline write" records all the current occurrences of a module.
+func runBaseline(args []string) {
+	if len(args) < 1 || args[0] != "write" {
+		fmt.Println("Usage: gosurf baseline write [-output baseline.json] [flags] <module_path>")
+		os.Exit(2)
+	}
+
+	var opts options
+	fs := flag.NewFlagSet("baseline write", flag.ExitOnError)
+	addAnalysisFlags(fs, &opts)
+	output := fs.String("output", "baseline.json", "baseline file to write")
+	fs.Usage = func() {
+		fmt.Println("Usage: gosurf baseline write [-output baseline.json] [flags] <module_path>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	modulePath := fs.Arg(0)
+
+	occurrences, _, err := analyzeModule(modulePath, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error analyzing module: %v\n", err)
+		os.Exit(1)
+	}
+
+	baseline := analysis.NewBaseline(analysis.GetModuleInfo(modulePath), occurrences)
+	if err := analysis.WriteBaseline(*output, baseline); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing baseline: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Baseline with %d occurrences written to %s\n", len(baseline.Findings), *output)
+}
+
+// Writes the capability lockfile of a module, or with -check compares the current
+// capabilities of the required modules to it. Exits with status 1 when a module gained a capability.
+func runLock(args []string) {
+	var opts options
+	fs := flag.NewFlagSet("lock", flag.ExitOnError)
+	addAnalysisFlags(fs, &opts)
+	check := fs.Bool("check", false, "fail if a required module uses a capability that is not in the lockfile")
+	lockPath := fs.String("file", "", "lockfile to write or check (default: gosurf.lock in the module directory)")
+	fs.Usage = func() {
+		fmt.Println("Usage: gosurf lock [-check] [-file gosurf.lock] [flags] <module_path>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	modulePath := fs.Arg(0)
+	if *lockPath == "" {
+		*lockPath = filepath.Join(modulePath, "gosurf.lock")
+	}
+
+	occurrences, _, err := analyzeModule(modulePath, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error analyzing module: %v\n", err)
+		os.Exit(1)
+	}
+	// required modules without occurrences are locked too, with no capability
+	required, _, err := analysis.GetRequiredModules(modulePath)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Error reading required modules: %v\n", err)
+		os.Exit(1)
+	}
+	current := analysis.NewLock(analysis.GetModuleInfo(modulePath), required, occurrences)
+
+	if !*check {
+		if err := analysis.WriteLock(*lockPath, current); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing lockfile: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Capabilities of %d modules written to %s\n", len(current.Modules), *lockPath)
+		return
+	}
+
+	lock, err := analysis.ReadLock(*lockPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading lockfile: %v\n", err)
+		os.Exit(2)
+	}
+	gained := 0
+	for _, change := range lock.Compare(current) {
+		from := change.OldVersion
+		if from == "" {
+			from = "not locked"
+		}
+		fmt.Printf("%s %s -> %s\n", change.Path, from, change.NewVersion)
+		for _, capability := range change.Added {
+			gained++
+			// show where the new capability is used
+			for _, occ := range occurrences {
+				if occ.ModulePath == change.Path && analysis.OccurrenceCapability(occ) == capability && !strings.HasSuffix(occ.FilePath, "_test.go") {
+					fmt.Printf("  + %s: %s\n", capability, formatOccurrence(occ))
+					break
+				}
+			}
+		}
+		for _, capability := range change.Removed {
+			fmt.Printf("  - %s\n", capability)
+		}
+	}
+
+	if gained > 0 {
+		fmt.Printf("%d capabilities not in %s\n", gained, *lockPath)
+		os.Exit(1)
+	}
+	fmt.Println("No new capabilities")
+}
+
+// Lists the non-Go files (C, assembly, .syso objects, ...) that the go tool picks up from
+// the packages of a module and of the modules it requires.
+func runFiles(args []string) {
+	var opts options
+	fs := flag.NewFlagSet("files", flag.ExitOnError)
+	addBuildFlags(fs, &opts)
+	format := fs.String("format", "text", "output format: text or json")
+	fs.Usage = func() {
+		fmt.Println("Usage: gosurf files [-format text|json] [-goos os] [-goarch arch] [-tags tags] <module_path>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	applyBuildFlags(opts)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown output format %q, expected text or json\n", *format)
+		os.Exit(2)
+	}
+
+	dependencies, missing, err := analysis.GetModuleDependencies(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error analyzing module: %v\n", err)
+		os.Exit(1)
+	}
+	for _, mod := range missing {
+		fmt.Fprintf(os.Stderr, "Module %s@%s not found in vendor/ or module cache, skipping (run 'go mod download')\n", mod.Path, mod.Version)
+	}
+	inventory, err := analysis.InventoryFiles(dependencies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing files: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *format == "json" {
+		if err := analysis.WriteJSONInventory(os.Stdout, inventory); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing JSON report: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	for _, file := range inventory {
+		marker := " "
+		if file.Kind == "object" {
+			marker = "!" // opaque binary
+		}
+		fmt.Printf("%s %-12s %10d  %s  %s\n", marker, file.Kind, file.Size, file.SHA256, file.Path)
+	}
+	fmt.Printf("%d non-Go files\n", len(inventory))
+}
+
+// Compares the occurrences of two versions of a module, given as directories or module@version.
+func runDiff(args []string) {
+	var opts options
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	addAnalysisFlags(fs, &opts)
+	format := fs.String("format", "text", "output format: text or json")
+	fs.Usage = func() {
+		fmt.Println("Usage: gosurf diff [flags] <old_module> <new_module>")
+		fmt.Println("Modules are directories or module@version pairs extracted in the module cache.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown output format %q, expected text or json\n", *format)
+		os.Exit(2)
+	}
+
+	var modules [2]analysis.Module
+	var occurrences [2][]*analysis.Occurrence
+	for idx, arg := range fs.Args()[:2] {
+		dir, err := analysis.ResolveModuleDir(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		modules[idx] = analysis.GetModuleInfo(dir)
+		fmt.Fprintf(os.Stderr, "Analyzing %s...\n", dir)
+		occurrences[idx], _, err = analyzeModule(dir, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error analyzing module: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	diff := analysis.DiffOccurrences(occurrences[0], occurrences[1])
+	if *format == "json" {
+		if err := analysis.WriteJSONDiffReport(os.Stdout, analysis.NewDiffReport(modules[0], modules[1], diff)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing JSON report: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	printDiff(os.Stdout, modules[0], modules[1], diff)
+}
+
+// Prints the differences per attack vector and per package, followed by the differing
+// occurrences. Added occurrences of high severity are listed first.
+func printDiff(out io.Writer, oldModule, newModule analysis.Module, diff analysis.OccurrenceDiff) {
+	byVector := diff.CountByVector()
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "╔═════════════════════════════════════════════════════════════════════════╗")
+	fmt.Fprintf(out, "║ %-71s ║\n", fmt.Sprintf("Attack Surface Diff: %s -> %s", moduleName(oldModule), moduleName(newModule)))
+	fmt.Fprintln(out, "╠═════════════════════════════════════════════════════════════════════════╣")
+	fmt.Fprintf(out, "║ %-41s%10s%10s%10s ║\n", "", "added", "removed", "changed")
+	for _, vector := range analysis.AttackVectors {
+		count := byVector[vector.Name]
+		fmt.Fprintf(out, "║ %-5s %-35s%10s%10s%10s ║\n", "["+vector.ID+"]", vector.Title+":", fmt.Sprintf("+%d", count.Added), fmt.Sprintf("-%d", count.Removed), fmt.Sprintf("~%d", count.Changed))
+	}
+	fmt.Fprintln(out, "╚═════════════════════════════════════════════════════════════════════════╝")
+
+	byPackage := diff.CountByPackage()
+	packages := make([]string, 0, len(byPackage))
+	for pkg := range byPackage {
+		packages = append(packages, pkg)
+	}
+	sort.Strings(packages)
+	if len(packages) > 0 {
+		fmt.Fprintln(out, "\nPer package:")
+	}
+	for _, pkg := range packages {
+		count := byPackage[pkg]
+		fmt.Fprintf(out, "  %s: +%d -%d ~%d\n", pkg, count.Added, count.Removed, count.Changed)
+	}
+
+	added := append([]*analysis.Occurrence(nil), diff.Added...)
+	sort.SliceStable(added, func(i, j int) bool {
+		return isHighSeverity(added[i]) && !isHighSeverity(added[j])
+	})
+	if len(added) > 0 {
+		fmt.Fprintln(out, "\nAdded:")
+	}
+	for _, occ := range added {
+		marker := " "
+		if isHighSeverity(occ) {
+			marker = "!"
+		}
+		fmt.Fprintf(out, "%s + %s\n", marker, formatOccurrence(occ))
+	}
+	if len(diff.Removed) > 0 {
+		fmt.Fprintln(out, "\nRemoved:")
+	}
+	for _, occ := range diff.Removed {
+		fmt.Fprintf(out, "  - %s\n", formatOccurrence(occ))
+	}
+	if len(diff.Changed) > 0 {
+		fmt.Fprintln(out, "\nChanged:")
+	}
+	for _, changed := range diff.Changed {
+		fmt.Fprintf(out, "  ~ %s\n      was: %s\n", formatOccurrence(changed.New), changed.Old.Snippet)
+	}
+}
+
+func isHighSeverity(occ *analysis.Occurrence) bool {
+	return analysis.OccurrenceSeverity(occ) == "high"
+}
+
+// Formats an occurrence on one line, e.g. "[E8] exec.Command in main.run (main.go:12)".
+func formatOccurrence(occ *analysis.Occurrence) string {
+	vector, _ := analysis.GetAttackVector(occ.AttackVector)
+	what := occ.Snippet
+	if occ.MethodInvoked != "" {
+		what = occ.MethodInvoked
+	}
+	where := occ.ImportPath
+	if occ.Function != "" {
+		where += "." + occ.Function
+	}
+	return fmt.Sprintf("[%s] %s in %s (%s:%d)", vector.ID, what, where, occ.FilePath, occ.LineNumber)
+}
+
+func moduleName(mod analysis.Module) string {
+	if mod.Version == "" {
+		return mod.Path
+	}
+	return mod.Path + "@" + mod.Version
+}
+
+// Analyzes a module and checks the occurrences against a policy file.
+// Exits with status 1 when the policy is violated.
+func runCheck(args []string) {
+	var opts options
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	addAnalysisFlags(fs, &opts)
+	fs.StringVar(&opts.baselinePath, "baseline", "", "only check occurrences that are not recorded in this baseline file")
+	policyPath := fs.String("policy", "", "policy file (JSON) with the rules to enforce")
+	fs.Usage = func() {
+		fmt.Println("Usage: gosurf check -policy <policy.json> [flags] <module_path>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || *policyPath == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	modulePath := fs.Arg(0)
+
+	policy, err := analysis.ReadPolicy(*policyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading policy: %v\n", err)
+		os.Exit(2)
+	}
+
+	occurrences, _, err := analyzeModule(modulePath, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error analyzing module: %v\n", err)
+		os.Exit(1)
+	}
+
+	violations := policy.Evaluate(analysis.GetModuleInfo(modulePath).Path, occurrences)
+	for _, violation := range violations {
+		location := violation.Module
+		if occ := violation.Occurrence; occ != nil {
+			location = fmt.Sprintf("%s:%d (%s)", occ.FilePath, occ.LineNumber, occ.ModulePath)
+			if occ.ModuleVersion != "" {
+				location = fmt.Sprintf("%s:%d (%s@%s)", occ.FilePath, occ.LineNumber, occ.ModulePath, occ.ModuleVersion)
+			}
+		}
+		fmt.Printf("rule %d: %s: %s\n", violation.Rule+1, violation.Message, location)
+	}
+
+	if len(violations) > 0 {
+		fmt.Printf("%d policy violations\n", len(violations))
+		os.Exit(1)
+	}
+	fmt.Println("No policy violations")
+}
+
+// Analyzes the module at modulePath and all the modules it requires.
+func analyzeModule(modulePath string, opts options) ([]*analysis.Occurrence, string, error) {
+	applyBuildFlags(opts)
+
+	// Get the packages of the module and of all the modules it requires (go.mod/go.sum)
+	dependencies, missingModules, err := analysis.GetModuleDependencies(modulePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("getting module dependencies: %v", err)
+	}
+	for _, mod := range missingModules {
+		fmt.Fprintf(os.Stderr, "Module %s@%s not found in vendor/ or module cache, skipping (run 'go mod download')\n", mod.Path, mod.Version)
+	}
+	// analysis.PrintDependencies(dependencies)
+
+	// Analyze all the module packages and dependencies, parsing each file once,
+	// and build the call graph of their functions in the same pass
+	graph := analysis.NewCallGraph()
+	parsers := append(append([]analysis.OccurrenceParser(nil), analysis.DefaultParsers...), graph)
+	occurrences := analysis.AnalyzeDependencies(dependencies, opts.workers, parsers...)
+
+	// Sensitive operations that can run when the packages are imported
+	occurrences = append(occurrences, graph.ImportTimeOccurrences()...)
+
+	// Tag occurrences in functions called from the module, or in packages linked into the build
+	note := ""
+	if opts.callGraph {
+		roots := analysis.GetModulePackages(dependencies, analysis.GetModuleInfo(modulePath).Path)
+		if opts.rootPackages != "" {
+			roots = analysis.GetRootPackages(dependencies, "", strings.Split(opts.rootPackages, ","))
+		}
+		linked := analysis.GetReachablePackages(dependencies, roots)
+		graph.MarkReachable(occurrences, roots, linked)
+		if opts.reachableOnly || opts.rootPackages != "" {
+			occurrences = analysis.FilterReachable(occurrences)
+		}
+		note = fmt.Sprintf(" (%d linked packages)", len(linked))
+	} else if opts.reachableOnly || opts.rootPackages != "" {
+		var patterns []string
+		if opts.rootPackages != "" {
+			patterns = strings.Split(opts.rootPackages, ",")
+		}
+		roots := analysis.GetRootPackages(dependencies, analysis.GetModuleInfo(modulePath).Path, patterns)
+		reachable := analysis.GetReachablePackages(dependencies, roots)
+		analysis.MarkReachable(occurrences, reachable)
+		occurrences = analysis.FilterReachable(occurrences)
+		note = fmt.Sprintf(" (%d reachable packages)", len(reachable))
+	}
+
+	// Suppress known occurrences
+	if opts.baselinePath != "" {
+		baseline, err := analysis.ReadBaseline(opts.baselinePath)
+		if err != nil {
+			return nil, "", fmt.Errorf("reading baseline: %v", err)
+		}
+		var suppressed int
+		occurrences, suppressed = baseline.Filter(occurrences)
+		note += fmt.Sprintf(" (%d in baseline)", suppressed)
+	}
+
+	return occurrences, note, nil
+}
+
+// Prints the unique occurrence counts for each attack vector in a box.
+func printSummary(out io.Writer, modulePath string, note string, occurrences []*analysis.Occurrence) {
+	counts := analysis.CountOccurrencesByVector(occurrences)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "╔═════════════════════════════════════════════════════════════════════════╗")
+	fmt.Fprintf(out, "║ Attack Surface Analysis: %s%s	     		         ║\n", filepath.Base(strings.TrimSuffix(modulePath, "/"+filepath.Base(modulePath))), note)
+	fmt.Fprintln(out, "╠═════════════════════════════════════════════════════════════════════════╣")
+	for _, vector := range analysis.AttackVectors {
+		fmt.Fprintf(out, "║ %-5s %-55s%10d ║\n", "["+vector.ID+"]", vector.Title+":", counts[vector.Name])
+	}
+	fmt.Fprintln(out, "╚═════════════════════════════════════════════════════════════════════════╝")
+
+	// Call chains from the initializers to the operations that run at import time
+	if importTime := analysis.FilterOccurrences(occurrences, "importtime"); len(importTime) > 0 {
+		fmt.Fprintf(out, "\n%d sensitive operations can run at import time:\n", len(importTime))
+		for _, occ := range importTime {
+			fmt.Fprintf(out, "  %s\n      %s\n", formatOccurrence(occ), strings.Join(occ.CallChain, " -> "))
+		}
+	}
+
+	// Example call paths from the module to the occurrences of its dependencies
+	module := analysis.GetModuleInfo(modulePath).Path
+	var called []*analysis.Occurrence
+	for _, occ := range occurrences {
+		if len(occ.CallPath) > 0 && occ.ModulePath != module {
+			called = append(called, occ)
+		}
+	}
+	if len(called) > 0 {
+		fmt.Fprintln(out, "\nOccurrences in dependencies reachable from the module:")
+		for _, occ := range called {
+			fmt.Fprintf(out, "  %s\n      %s\n", formatOccurrence(occ), strings.Join(occ.CallPath, " -> "))
+		}
+		fmt.Fprintf(out, "%d occurrences in dependencies are reachable from the module\n", len(called))
+	}
+
+	// Occurrences behind ignore or custom build tags are not built normally, which makes them suspicious
+	var unusual []*analysis.Occurrence
+	for _, occ := range occurrences {
+		if len(occ.UnusualTags) > 0 {
+			unusual = append(unusual, occ)
+		}
+	}
+	if len(unusual) > 0 {
+		fmt.Fprintf(out, "\n%d occurrences in files guarded by unusual build tags:\n", len(unusual))
+		for _, occ := range unusual {
+			fmt.Fprintf(out, "  %s [%s]\n", formatOccurrence(occ), occ.BuildConstraint)
+		}
+	}
 }
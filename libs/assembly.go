@@ -0,0 +1,135 @@
+package libs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A function implemented in an assembly file, declared by a TEXT directive.
+type AsmFunction struct {
+	Name        string   `json:"Name"`
+	Package     string   `json:"Package,omitempty"` // qualifier before ·, e.g. runtime; empty for the current package
+	File        string   `json:"File"`
+	Line        int      `json:"Line"`
+	Flags       []string `json:"Flags,omitempty"` // e.g. NOSPLIT, NOFRAME
+	FrameSize   int      `json:"FrameSize"`
+	ArgsSize    int      `json:"ArgsSize"`              // -1 if not given
+	Declaration string   `json:"Declaration,omitempty"` // position of the Go declaration without body, e.g. main.go:7
+	Syscalls    []int    `json:"Syscalls,omitempty"`    // lines of direct system call instructions
+}
+
+// TEXT pkg·name<ABI>(SB), flags, $frame-args
+var textRegex = regexp.MustCompile(`^TEXT\s+([^\s(]*)\(SB\)\s*(.*)$`)
+
+// Instructions entering the kernel directly: SYSCALL (amd64, mips, s390x), SYSENTER and INT $0x80 (386),
+// SVC and SWI (arm, arm64), ECALL (riscv64) and SC (ppc64)
+var asmSyscallRegex = regexp.MustCompile(`^(SYSCALL|SYSENTER|SVC|SWI|ECALL|SC|INT\s+\$(0x80|128))\b`)
+
+// Parses the TEXT symbols of an assembly file, with their flags, frame sizes and the
+// system call instructions in their bodies.
+func ParseAsmFile(path string) ([]AsmFunction, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var functions []AsmFunction
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if idx := strings.Index(line, "//"); idx >= 0 {
+			line = line[:idx]
+		}
+		// several instructions can be written on one line, separated by ;
+		for _, instr := range strings.Split(line, ";") {
+			instr = strings.TrimSpace(instr)
+			if match := textRegex.FindStringSubmatch(instr); match != nil {
+				if function, ok := parseTextDirective(match[1], match[2]); ok {
+					function.File = path
+					function.Line = lineNumber
+					functions = append(functions, function)
+				}
+				continue
+			}
+			if len(functions) > 0 && asmSyscallRegex.MatchString(instr) {
+				last := &functions[len(functions)-1]
+				last.Syscalls = append(last.Syscalls, lineNumber)
+			}
+		}
+	}
+	return functions, scanner.Err()
+}
+
+// Parses the symbol and the arguments of a TEXT directive, e.g. ·Add(SB), NOSPLIT|NOFRAME, $0-24.
+func parseTextDirective(symbol string, args string) (AsmFunction, bool) {
+	// the ABI selector is not part of the name, e.g. ·Add<ABIInternal>
+	if idx := strings.Index(symbol, "<"); idx >= 0 {
+		symbol = symbol[:idx]
+	}
+	pkg, name, found := strings.Cut(symbol, "·")
+	if !found || name == "" {
+		return AsmFunction{}, false
+	}
+	function := AsmFunction{Name: name, Package: strings.ReplaceAll(pkg, "∕", "/"), ArgsSize: -1}
+
+	for _, arg := range strings.Split(strings.TrimPrefix(args, ","), ",") {
+		arg = strings.TrimSpace(arg)
+		switch {
+		case arg == "":
+		case strings.HasPrefix(arg, "$"):
+			frame, argsSize, hasArgs := strings.Cut(arg[1:], "-")
+			function.FrameSize, _ = strconv.Atoi(frame)
+			if hasArgs {
+				function.ArgsSize, _ = strconv.Atoi(argsSize)
+			}
+		default:
+			for _, flag := range strings.Split(arg, "|") {
+				function.Flags = append(function.Flags, strings.TrimSpace(flag))
+			}
+		}
+	}
+	return function, true
+}
+
+// Matches the assembly functions of the current package to the Go functions declared
+// without body, e.g. func Add(x, y int) int.
+func matchAsmDeclarations(functions []AsmFunction, declarations map[string]string) {
+	for idx := range functions {
+		if functions[idx].Package == "" {
+			functions[idx].Declaration = declarations[functions[idx].Name]
+		}
+	}
+}
+
+// Reads the lines of an assembly file.
+func asmLines(path string) []string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(content), "\n")
+}
+
+// Gets the Go functions declared without body, with their positions as file:line.
+func bodylessFunctions(fset *token.FileSet, files []*ast.File) map[string]string {
+	declarations := make(map[string]string)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body != nil || fn.Recv != nil {
+				continue
+			}
+			pos := fset.Position(fn.Pos())
+			declarations[fn.Name.Name] = fmt.Sprintf("%s:%d", filepath.Base(pos.Filename), pos.Line)
+		}
+	}
+	return declarations
+}
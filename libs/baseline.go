@@ -0,0 +1,150 @@
+package libs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go/ast"
+	"go/types"
+	"os"
+	"strings"
+)
+
+// A baseline records known occurrences, so that later runs only report new ones.
+type Baseline struct {
+	SchemaVersion string            `json:"SchemaVersion"`
+	Tool          ReportTool        `json:"Tool"`
+	Module        ReportModule      `json:"Module"`
+	Findings      []BaselineFinding `json:"Findings"`
+}
+
+type BaselineFinding struct {
+	Fingerprint string `json:"Fingerprint"`
+	Vector      string `json:"Vector"`
+	Package     string `json:"Package"`
+	Function    string `json:"Function,omitempty"`
+	Snippet     string `json:"Snippet,omitempty"`
+}
+
+// Gets a fingerprint of the occurrence that is stable across unrelated edits: it is based
+// on the attack vector, the package, the enclosing function and the normalized source line,
+// but not on the file path or line number.
+func Fingerprint(occ *Occurrence) string {
+	hash := sha256.Sum256([]byte(strings.Join([]string{occ.AttackVector, occurrencePackage(occ), occ.Function, occ.Snippet}, "\x00")))
+	return hex.EncodeToString(hash[:])
+}
+
+// Builds a baseline with all the occurrences found in the module.
+func NewBaseline(module Module, occurrences []*Occurrence) Baseline {
+	baseline := Baseline{
+		SchemaVersion: ReportSchemaVersion,
+		Tool:          ReportTool{Name: ToolName, Version: ToolVersion},
+		Module:        ReportModule{Path: module.Path, Version: module.Version},
+		Findings:      make([]BaselineFinding, 0, len(occurrences)),
+	}
+	for _, occ := range occurrences {
+		baseline.Findings = append(baseline.Findings, BaselineFinding{
+			Fingerprint: Fingerprint(occ),
+			Vector:      occ.AttackVector,
+			Package:     occurrencePackage(occ),
+			Function:    occ.Function,
+			Snippet:     occ.Snippet,
+		})
+	}
+	return baseline
+}
+
+func ReadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, err
+	}
+	return &baseline, nil
+}
+
+func WriteBaseline(path string, baseline Baseline) error {
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Gets the occurrences not recorded in the baseline, and the number of suppressed ones.
+// A fingerprint recorded n times suppresses at most n occurrences, so a copy of a known
+// line in the same function is still reported.
+func (baseline *Baseline) Filter(occurrences []*Occurrence) ([]*Occurrence, int) {
+	known := make(map[string]int)
+	for _, finding := range baseline.Findings {
+		known[finding.Fingerprint]++
+	}
+
+	var result []*Occurrence
+	suppressed := 0
+	for _, occ := range occurrences {
+		fingerprint := Fingerprint(occ)
+		if known[fingerprint] > 0 {
+			known[fingerprint]--
+			suppressed++
+			continue
+		}
+		result = append(result, occ)
+	}
+	return result, suppressed
+}
+
+func occurrencePackage(occ *Occurrence) string {
+	if occ.ImportPath != "" {
+		return occ.ImportPath
+	}
+	return occ.PackageName
+}
+
+// Sets the enclosing function and the source snippet of occurrences found in file.
+func annotateOccurrences(file *SourceFile, occurrences []*Occurrence) {
+	if len(occurrences) == 0 {
+		return
+	}
+	for _, occ := range occurrences {
+		if occ.FilePath != file.Path {
+			// e.g. assembly or object files of the package, annotated by their parser
+			continue
+		}
+		if occ.Snippet == "" {
+			// parsers can set their own snippet, e.g. for embedded files
+			occ.Snippet = file.Line(occ.LineNumber)
+		}
+		occ.Function = enclosingFunction(file, occ.LineNumber)
+	}
+}
+
+// Gets the name of the function declaration enclosing a line of file, empty at package level.
+func enclosingFunction(file *SourceFile, line int) string {
+	for _, decl := range file.Ast.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		start, end := file.Fset.Position(fn.Pos()).Line, file.Fset.Position(fn.End()).Line
+		if line >= start && line <= end {
+			return funcDeclName(fn)
+		}
+	}
+	return ""
+}
+
+// Gets the name of a function declaration, e.g. Foo, T.Bar or (*T).Baz.
+func funcDeclName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		return "(*" + types.ExprString(star.X) + ")." + fn.Name.Name
+	}
+	return types.ExprString(recv) + "." + fn.Name.Name
+}
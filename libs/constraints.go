@@ -0,0 +1,202 @@
+package libs
+
+import (
+	"bufio"
+	"bytes"
+	"go/build"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Build context used to select the files of a package, as the go tool does. When nil,
+// every file is analyzed, whatever its build constraints.
+var BuildContext *build.Context
+
+// Builds a context for the given GOOS, GOARCH and build tags. Empty values keep the
+// defaults of the toolchain. Like the go tool, cgo is disabled when cross-compiling,
+// unless CGO_ENABLED is set.
+func NewBuildContext(goos, goarch string, tags []string) *build.Context {
+	ctx := build.Default
+	if goos != "" {
+		ctx.GOOS = goos
+	}
+	if goarch != "" {
+		ctx.GOARCH = goarch
+	}
+	if os.Getenv("CGO_ENABLED") == "" && (ctx.GOOS != runtime.GOOS || ctx.GOARCH != runtime.GOARCH) {
+		ctx.CgoEnabled = false
+	}
+	ctx.BuildTags = append([]string(nil), tags...)
+	return &ctx
+}
+
+// Reports whether the file name in dir is selected by BuildContext.
+// Files whose constraints cannot be read are kept.
+func matchFile(dir, name string) bool {
+	if BuildContext == nil {
+		return true
+	}
+	match, err := BuildContext.MatchFile(dir, name)
+	if err != nil {
+		return true
+	}
+	// like the go tool, files importing "C" are ignored when cgo is disabled
+	if match && !BuildContext.CgoEnabled && strings.HasSuffix(name, ".go") {
+		return !importsC(filepath.Join(dir, name))
+	}
+	return match
+}
+
+// Reports whether a Go file imports "C".
+func importsC(path string) bool {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+	if err != nil {
+		return false
+	}
+	for _, imp := range file.Imports {
+		if imp.Path.Value == `"C"` {
+			return true
+		}
+	}
+	return false
+}
+
+// Operating systems and architectures known to the go tool (go/build/syslist.go)
+var (
+	knownOS   = []string{"aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios", "js", "linux", "nacl", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows", "zos"}
+	knownArch = []string{"386", "amd64", "amd64p32", "arm", "armbe", "arm64", "arm64be", "loong64", "mips", "mipsle", "mips64", "mips64le", "mips64p32", "mips64p32le", "ppc", "ppc64", "ppc64le", "riscv", "riscv64", "s390", "s390x", "sparc", "sparc64", "wasm"}
+	// other tags set by the go tool or commonly used by the standard library
+	knownTags = []string{"cgo", "gc", "gccgo", "unix", "race", "msan", "asan", "purego", "boringcrypto"}
+)
+
+var goVersionTagRegex = regexp.MustCompile(`^go1\.\d+$`)
+
+// Gets the build constraint guarding a file: its //go:build (or // +build) expression and
+// the GOOS/GOARCH implied by its name, e.g. "linux && amd64" for sys_linux_amd64.go.
+func fileConstraint(name string, content []byte) string {
+	var parts []string
+	goos, goarch := fileNameConstraint(name)
+	for _, tag := range []string{goos, goarch} {
+		if tag != "" {
+			parts = append(parts, tag)
+		}
+	}
+	if expr := headerConstraint(content); expr != nil {
+		if _, isOr := expr.(*constraint.OrExpr); isOr && len(parts) > 0 {
+			parts = append(parts, "("+expr.String()+")")
+		} else {
+			parts = append(parts, expr.String())
+		}
+	}
+	return strings.Join(parts, " && ")
+}
+
+// Gets the build constraint of the file in path, see fileConstraint. The content of
+// binary objects is not read.
+func pathConstraint(path string) string {
+	var content []byte
+	if filepath.Ext(path) != ".syso" {
+		content, _ = os.ReadFile(path)
+	}
+	return fileConstraint(filepath.Base(path), content)
+}
+
+// Parses the //go:build line of the file header, or the // +build lines of older files.
+func headerConstraint(content []byte) constraint.Expr {
+	var plusBuild constraint.Expr
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "//") {
+			// constraints must appear before the package clause
+			break
+		}
+		if constraint.IsGoBuild(line) {
+			if expr, err := constraint.Parse(line); err == nil {
+				return expr
+			}
+		}
+		if constraint.IsPlusBuild(line) {
+			if expr, err := constraint.Parse(line); err == nil {
+				if plusBuild == nil {
+					plusBuild = expr
+				} else {
+					plusBuild = &constraint.AndExpr{X: plusBuild, Y: expr}
+				}
+			}
+		}
+	}
+	return plusBuild
+}
+
+// Gets the GOOS and GOARCH implied by a file name, following the rules of the go tool:
+// name_GOOS_GOARCH.ext, name_GOOS.ext or name_GOARCH.ext, optionally followed by _test.
+func fileNameConstraint(name string) (goos, goarch string) {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	name = strings.TrimSuffix(name, "_test")
+	idx := strings.Index(name, "_")
+	if idx < 0 {
+		return "", ""
+	}
+	elems := strings.Split(name[idx:], "_")
+	n := len(elems)
+	if n >= 2 && slices.Contains(knownOS, elems[n-2]) && slices.Contains(knownArch, elems[n-1]) {
+		return elems[n-2], elems[n-1]
+	}
+	if slices.Contains(knownOS, elems[n-1]) {
+		return elems[n-1], ""
+	}
+	if slices.Contains(knownArch, elems[n-1]) {
+		return "", elems[n-1]
+	}
+	return "", ""
+}
+
+// Gets the tags of a build constraint that are neither a GOOS, a GOARCH, a Go version,
+// a tag set by the go tool nor a tag of BuildContext, e.g. ignore.
+func unusualTags(expr string) []string {
+	if expr == "" {
+		return nil
+	}
+	parsed, err := constraint.Parse("//go:build " + expr)
+	if err != nil {
+		return []string{expr}
+	}
+
+	var unusual []string
+	parsed.Eval(func(tag string) bool {
+		known := slices.Contains(knownOS, tag) || slices.Contains(knownArch, tag) || slices.Contains(knownTags, tag) ||
+			goVersionTagRegex.MatchString(tag) || strings.HasPrefix(tag, "goexperiment.")
+		if BuildContext != nil {
+			known = known || slices.Contains(BuildContext.BuildTags, tag)
+		}
+		if !known && !slices.Contains(unusual, tag) {
+			unusual = append(unusual, tag)
+		}
+		return false
+	})
+	return unusual
+}
+
+// Sets the build constraint of occurrences found in file, and its unusual tags.
+func annotateConstraints(file *SourceFile, occurrences []*Occurrence) {
+	for _, occ := range occurrences {
+		if occ.FilePath == file.Path {
+			occ.BuildConstraint = file.Constraint
+		} else {
+			// e.g. assembly or object files of the package
+			occ.BuildConstraint = pathConstraint(occ.FilePath)
+		}
+		occ.UnusualTags = unusualTags(occ.BuildConstraint)
+	}
+}
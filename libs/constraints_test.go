@@ -0,0 +1,47 @@
+package libs
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuildContextFileSelection(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.go":               "package m\n",
+		"a_linux.go":         "package m\n",
+		"a_windows_amd64.go": "package m\n",
+		"tagged.go":          "//go:build custom\n\npackage m\n",
+		"cgo.go":             "package m\n\nimport \"C\"\n",
+		"nocgo.go":           "//go:build !cgo\n\npackage m\n",
+	})
+	// cgo is disabled when cross-compiling
+	t.Setenv("CGO_ENABLED", "")
+	saved := BuildContext
+	defer func() { BuildContext = saved }()
+
+	tests := []struct {
+		goos, goarch string
+		tags         []string
+		want         []string
+	}{
+		{"windows", "amd64", nil, []string{"a.go", "a_windows_amd64.go", "nocgo.go"}},
+		{"windows", "386", nil, []string{"a.go", "nocgo.go"}},
+		{"linux", "s390x", []string{"custom"}, []string{"a.go", "a_linux.go", "nocgo.go", "tagged.go"}},
+	}
+	for _, test := range tests {
+		BuildContext = NewBuildContext(test.goos, test.goarch, test.tags)
+		pkg, err := LoadPackage(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, file := range pkg.Files {
+			got = append(got, filepath.Base(file.Path))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s/%s %v: got %v, want %v", test.goos, test.goarch, test.tags, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package libs
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Differences between the occurrences of two versions of a module.
+type OccurrenceDiff struct {
+	Added   []*Occurrence
+	Removed []*Occurrence
+	Changed []ChangedOccurrence
+}
+
+// An occurrence at the same site (vector, package, function and invoked method) whose source changed.
+type ChangedOccurrence struct {
+	Old *Occurrence
+	New *Occurrence
+}
+
+type DiffCount struct {
+	Added   int `json:"Added"`
+	Removed int `json:"Removed"`
+	Changed int `json:"Changed"`
+}
+
+type DiffReport struct {
+	SchemaVersion string                  `json:"SchemaVersion"`
+	Tool          ReportTool              `json:"Tool"`
+	Old           ReportModule            `json:"Old"`
+	New           ReportModule            `json:"New"`
+	ByVector      map[string]DiffCount    `json:"ByVector"`
+	ByPackage     map[string]DiffCount    `json:"ByPackage"`
+	Added         []OccurrenceJSON        `json:"Added"`
+	Removed       []OccurrenceJSON        `json:"Removed"`
+	Changed       []ChangedOccurrenceJSON `json:"Changed"`
+}
+
+type ChangedOccurrenceJSON struct {
+	Old OccurrenceJSON `json:"Old"`
+	New OccurrenceJSON `json:"New"`
+}
+
+// Compares the occurrences of an old and a new version of a module. Occurrences with the
+// same fingerprint are unchanged. The remaining ones at the same site are changed, and
+// the others are added or removed.
+func DiffOccurrences(oldOccurrences, newOccurrences []*Occurrence) OccurrenceDiff {
+	oldBySite := groupBySite(oldOccurrences)
+	newBySite := groupBySite(newOccurrences)
+
+	sites := make(map[string]struct{})
+	for site := range oldBySite {
+		sites[site] = struct{}{}
+	}
+	for site := range newBySite {
+		sites[site] = struct{}{}
+	}
+	sortedSites := make([]string, 0, len(sites))
+	for site := range sites {
+		sortedSites = append(sortedSites, site)
+	}
+	sort.Strings(sortedSites)
+
+	var diff OccurrenceDiff
+	for _, site := range sortedSites {
+		oldRemaining := withoutFingerprints(oldBySite[site], newBySite[site])
+		newRemaining := withoutFingerprints(newBySite[site], oldBySite[site])
+
+		paired := min(len(oldRemaining), len(newRemaining))
+		for i := 0; i < paired; i++ {
+			diff.Changed = append(diff.Changed, ChangedOccurrence{Old: oldRemaining[i], New: newRemaining[i]})
+		}
+		diff.Removed = append(diff.Removed, oldRemaining[paired:]...)
+		diff.Added = append(diff.Added, newRemaining[paired:]...)
+	}
+	return diff
+}
+
+// Counts the differences per attack vector.
+func (diff OccurrenceDiff) CountByVector() map[string]DiffCount {
+	return diff.countBy(func(occ *Occurrence) string { return occ.AttackVector })
+}
+
+// Counts the differences per package import path.
+func (diff OccurrenceDiff) CountByPackage() map[string]DiffCount {
+	return diff.countBy(occurrencePackage)
+}
+
+func (diff OccurrenceDiff) countBy(key func(*Occurrence) string) map[string]DiffCount {
+	counts := make(map[string]DiffCount)
+	for _, occ := range diff.Added {
+		count := counts[key(occ)]
+		count.Added++
+		counts[key(occ)] = count
+	}
+	for _, occ := range diff.Removed {
+		count := counts[key(occ)]
+		count.Removed++
+		counts[key(occ)] = count
+	}
+	for _, changed := range diff.Changed {
+		count := counts[key(changed.New)]
+		count.Changed++
+		counts[key(changed.New)] = count
+	}
+	return counts
+}
+
+// Builds the JSON report of the differences between two versions of a module.
+func NewDiffReport(oldModule, newModule Module, diff OccurrenceDiff) DiffReport {
+	report := DiffReport{
+		SchemaVersion: ReportSchemaVersion,
+		Tool:          ReportTool{Name: ToolName, Version: ToolVersion},
+		Old:           ReportModule{Path: oldModule.Path, Version: oldModule.Version},
+		New:           ReportModule{Path: newModule.Path, Version: newModule.Version},
+		ByVector:      diff.CountByVector(),
+		ByPackage:     diff.CountByPackage(),
+		Added:         make([]OccurrenceJSON, 0, len(diff.Added)),
+		Removed:       make([]OccurrenceJSON, 0, len(diff.Removed)),
+		Changed:       make([]ChangedOccurrenceJSON, 0, len(diff.Changed)),
+	}
+	for _, occ := range diff.Added {
+		report.Added = append(report.Added, toOccurrenceJSON(occ))
+	}
+	for _, occ := range diff.Removed {
+		report.Removed = append(report.Removed, toOccurrenceJSON(occ))
+	}
+	for _, changed := range diff.Changed {
+		report.Changed = append(report.Changed, ChangedOccurrenceJSON{Old: toOccurrenceJSON(changed.Old), New: toOccurrenceJSON(changed.New)})
+	}
+	return report
+}
+
+func WriteJSONDiffReport(w io.Writer, report DiffReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(report)
+}
+
+// Gets the key of the site of an occurrence, which does not depend on its source line.
+func occurrenceSite(occ *Occurrence) string {
+	return strings.Join([]string{occ.AttackVector, occurrencePackage(occ), occ.Function, occ.MethodInvoked, occ.VariableName, occ.Pattern}, "\x00")
+}
+
+func groupBySite(occurrences []*Occurrence) map[string][]*Occurrence {
+	bySite := make(map[string][]*Occurrence)
+	for _, occ := range occurrences {
+		site := occurrenceSite(occ)
+		bySite[site] = append(bySite[site], occ)
+	}
+	return bySite
+}
+
+// Gets the occurrences whose fingerprint does not appear in others, matching each fingerprint once.
+func withoutFingerprints(occurrences []*Occurrence, others []*Occurrence) []*Occurrence {
+	counts := make(map[string]int)
+	for _, occ := range others {
+		counts[Fingerprint(occ)]++
+	}
+	var result []*Occurrence
+	for _, occ := range occurrences {
+		fingerprint := Fingerprint(occ)
+		if counts[fingerprint] > 0 {
+			counts[fingerprint]--
+			continue
+		}
+		result = append(result, occ)
+	}
+	return result
+}
@@ -0,0 +1,49 @@
+package libs
+
+import (
+	"testing"
+)
+
+func TestDiffOccurrences(t *testing.T) {
+	analyze := func(source string) []*Occurrence {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{"m.go": source})
+		dep := Dependency{Name: "m", Path: dir, ImportPath: "example.com/m"}
+		return AnalyzeDependencies([]Dependency{dep}, 1, ExecParser{})
+	}
+	oldOccurrences := analyze(`package m
+
+import "os/exec"
+
+func unchanged() { exec.Command("ls").Run() }
+
+func changed() { exec.Command("ls").Run() }
+
+func removed() { exec.Command("rm").Run() }
+`)
+	// the unchanged call moves down, which changes its line but not its fingerprint
+	newOccurrences := analyze(`package m
+
+import "os/exec"
+
+// Lists the files.
+//
+// It moves the call below.
+func unchanged() { exec.Command("ls").Run() }
+
+func changed() { exec.Command("curl", "https://evil.example").Run() }
+
+func added() { exec.Command("sh").Run() }
+`)
+
+	diff := DiffOccurrences(oldOccurrences, newOccurrences)
+	if len(diff.Added) != 1 || diff.Added[0].Function != "added" {
+		t.Errorf("got added %v, want the call in added", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Function != "removed" {
+		t.Errorf("got removed %v, want the call in removed", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].New.Function != "changed" || diff.Changed[0].Old.LineNumber != 7 || diff.Changed[0].New.LineNumber != 10 {
+		t.Errorf("got changed %v, want the call in changed", diff.Changed)
+	}
+}
@@ -0,0 +1,219 @@
+package libs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A variable initialized with files of the package directory by a //go:embed directive.
+type embedVar struct {
+	Name     string
+	Object   types.Object
+	Pos      token.Pos // position of the directive
+	Patterns []string
+}
+
+// Magic bytes of the file formats that can carry a payload, by kind
+var embedMagics = []struct {
+	kind  string
+	magic []byte
+}{
+	{"elf", []byte("\x7fELF")},
+	{"mach-o", []byte{0xfe, 0xed, 0xfa, 0xce}},
+	{"mach-o", []byte{0xfe, 0xed, 0xfa, 0xcf}},
+	{"mach-o", []byte{0xce, 0xfa, 0xed, 0xfe}},
+	{"mach-o", []byte{0xcf, 0xfa, 0xed, 0xfe}},
+	{"mach-o", []byte{0xca, 0xfe, 0xba, 0xbe}}, // universal binary
+	{"wasm", []byte("\x00asm")},
+	{"shell", []byte("#!")},
+	{"archive", []byte("PK\x03\x04")},           // zip, jar, apk
+	{"archive", []byte{0x1f, 0x8b}},             // gzip
+	{"archive", []byte("BZh")},                  // bzip2
+	{"archive", []byte("\xfd7zXZ\x00")},         // xz
+	{"archive", []byte("7z\xbc\xaf\x27\x1c")},   // 7-Zip
+	{"archive", []byte("!<arch>\n")},            // ar, e.g. static libraries
+	{"archive", []byte{0x28, 0xb5, 0x2f, 0xfd}}, // zstd
+}
+
+// Kinds of embedded files that are executable as they are
+var executableKinds = []string{"elf", "mach-o", "pe", "wasm", "shell"}
+
+// Classifies the content of a file by its magic bytes: elf, mach-o, pe, wasm, shell,
+// archive, or data for any other content.
+func classifyMagic(header []byte) string {
+	if isPE(header) {
+		return "pe"
+	}
+	for _, entry := range embedMagics {
+		if bytes.HasPrefix(header, entry.magic) {
+			return entry.kind
+		}
+	}
+	// tar archives have their magic at offset 257
+	if len(header) >= 262 && string(header[257:262]) == "ustar" {
+		return "archive"
+	}
+	return "data"
+}
+
+// Reports whether data starts with a PE executable: an MS-DOS header (MZ) whose e_lfanew
+// field, at offset 0x3c, points to the PE signature. Text starting with MZ is not a PE file.
+func isPE(header []byte) bool {
+	if len(header) < 0x40 || !bytes.HasPrefix(header, []byte("MZ")) {
+		return false
+	}
+	offset := int(binary.LittleEndian.Uint32(header[0x3c:]))
+	return offset+4 <= len(header) && string(header[offset:offset+4]) == "PE\x00\x00"
+}
+
+// Reads the first bytes of a file, enough to classify it.
+func readHeader(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// the PE signature is usually within the first 512 bytes, but can be further
+	header := make([]byte, 4096)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return header[:n], nil
+}
+
+// Gets the variables of a file initialized by //go:embed directives, with their patterns.
+func embedVars(file *SourceFile) []embedVar {
+	var vars []embedVar
+	for _, decl := range file.Ast.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			doc := vs.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+			if doc == nil || len(vs.Names) != 1 {
+				continue
+			}
+			embedded := embedVar{Name: vs.Names[0].Name, Object: file.Info.Defs[vs.Names[0]]}
+			for _, comment := range doc.List {
+				args, found := strings.CutPrefix(comment.Text, "//go:embed")
+				if !found || (args != "" && args[0] != ' ' && args[0] != '\t') {
+					continue
+				}
+				if embedded.Pos == token.NoPos {
+					embedded.Pos = comment.Pos()
+				}
+				embedded.Patterns = append(embedded.Patterns, splitEmbedPatterns(args)...)
+			}
+			if len(embedded.Patterns) > 0 {
+				vars = append(vars, embedded)
+			}
+		}
+	}
+	return vars
+}
+
+// Splits the arguments of a //go:embed directive, which are separated by spaces and can be
+// quoted with double quotes or back quotes.
+func splitEmbedPatterns(args string) []string {
+	var patterns []string
+	args = strings.TrimSpace(args)
+	for args != "" {
+		var pattern string
+		if args[0] == '"' || args[0] == '`' {
+			quoted, err := strconv.QuotedPrefix(args)
+			if err != nil {
+				break
+			}
+			pattern, _ = strconv.Unquote(quoted)
+			args = args[len(quoted):]
+		} else {
+			pattern, args, _ = strings.Cut(args, " ")
+		}
+		if pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+		args = strings.TrimSpace(args)
+	}
+	return patterns
+}
+
+// Resolves an embed pattern against the package directory, as the go tool does: matched
+// directories are embedded recursively, except files whose name starts with . or _ unless
+// the pattern has the all: prefix. Gets the matched files, relative to dir and sorted.
+func resolveEmbedPattern(dir, pattern string) []string {
+	pattern, all := strings.CutPrefix(pattern, "all:")
+	if pattern == "" || strings.HasPrefix(pattern, "/") || slices.Contains(strings.Split(pattern, "/"), "..") {
+		// rejected by the go tool
+		return nil
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			if rel, err := filepath.Rel(dir, match); err == nil {
+				files = append(files, filepath.ToSlash(rel))
+			}
+			continue
+		}
+		filepath.WalkDir(match, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			name := entry.Name()
+			if path != match && !all && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if entry.Type().IsRegular() {
+				if rel, err := filepath.Rel(dir, path); err == nil {
+					files = append(files, filepath.ToSlash(rel))
+				}
+			}
+			return nil
+		})
+	}
+	sort.Strings(files)
+	return slices.Compact(files)
+}
+
+// Gets the objects of the package that hold data of the embedded variables: the variables
+// themselves and the variables assigned from expressions using them, e.g. data in
+// data, _ := content.ReadFile("payload").
+func embedTaint(pkg *TypedPackage, vars []embedVar) map[types.Object]string {
+	tainted := make(map[types.Object]string)
+	for _, v := range vars {
+		if v.Object != nil {
+			tainted[v.Object] = v.Name
+		}
+	}
+	propagateTaint(pkg, tainted, nil)
+	return tainted
+}
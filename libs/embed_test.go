@@ -0,0 +1,35 @@
+package libs
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestClassifyMagic(t *testing.T) {
+	pe := make([]byte, 0x100)
+	copy(pe, "MZ")
+	binary.LittleEndian.PutUint32(pe[0x3c:], 0x80)
+	copy(pe[0x80:], "PE\x00\x00")
+
+	// e_lfanew points outside of the file
+	truncated := append([]byte(nil), pe[:0x80]...)
+
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{"pe", pe, "pe"},
+		{"pe truncated", truncated, "data"},
+		{"text starting with MZ", []byte("MZ is the postal code of a text file that is long enough to be checked for a PE header"), "data"},
+		{"elf", []byte("\x7fELF\x02\x01\x01"), "elf"},
+		{"shell", []byte("#!/bin/sh\necho hi\n"), "shell"},
+		{"gzip", []byte{0x1f, 0x8b, 0x08, 0x00}, "archive"},
+		{"text", []byte("hello"), "data"},
+	}
+	for _, test := range tests {
+		if got := classifyMagic(test.header); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package libs
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// Analyzes the dependencies in parallel with a bounded pool of workers.
+// Every package collects its occurrences in its own slice, and the slices are merged
+// in the order of dependencies, so the result does not depend on scheduling.
+func AnalyzeDependencies(dependencies []Dependency, workers int, parsers ...OccurrenceParser) []*Occurrence {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([][]*Occurrence, len(dependencies))
+	jobs := make(chan int)
+
+	var mu sync.Mutex
+	processed := 0
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				var occurrences []*Occurrence
+				AnalyzePackage(dependencies[idx], &occurrences, parsers...)
+				results[idx] = occurrences
+
+				mu.Lock()
+				processed++
+				updateProgressBar(processed, len(dependencies))
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for idx := range dependencies {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+	if len(dependencies) > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	var occurrences []*Occurrence
+	for _, result := range results {
+		occurrences = append(occurrences, result...)
+	}
+	return occurrences
+}
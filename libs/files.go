@@ -0,0 +1,165 @@
+package libs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// A non-Go file of a package that the go tool compiles, assembles or links into the build.
+type PackageFile struct {
+	Path    string
+	Kind    string // c, c++, objective-c, fortran, header, assembly, swig or object
+	Size    int64
+	SHA256  string
+	Symbols []AsmFunction // TEXT symbols of assembly files
+}
+
+// Kinds of the files picked up by the go tool, by extension. C, C++, Objective-C and Fortran
+// files are compiled in cgo packages, assembly files in every package, and .syso objects are
+// linked as they are.
+var packageFileKinds = map[string]string{
+	".c":       "c",
+	".cc":      "c++",
+	".cpp":     "c++",
+	".cxx":     "c++",
+	".m":       "objective-c",
+	".f":       "fortran",
+	".F":       "fortran",
+	".for":     "fortran",
+	".f90":     "fortran",
+	".h":       "header",
+	".hh":      "header",
+	".hpp":     "header",
+	".hxx":     "header",
+	".s":       "assembly",
+	".S":       "assembly",
+	".sx":      "assembly",
+	".swig":    "swig",
+	".swigcxx": "swig",
+	".syso":    "object",
+}
+
+// A non-Go file of a dependency, as listed in the file inventory.
+type DependencyFile struct {
+	ImportPath    string        `json:"ImportPath"`
+	ModulePath    string        `json:"ModulePath,omitempty"`
+	ModuleVersion string        `json:"ModuleVersion,omitempty"`
+	Path          string        `json:"Path"`
+	Kind          string        `json:"Kind"`
+	Size          int64         `json:"Size"`
+	SHA256        string        `json:"SHA256"`
+	Symbols       []AsmFunction `json:"Symbols,omitempty"`
+}
+
+// Lists the non-Go files of the package in dir that the go tool picks up, sorted by path.
+func ListPackageFiles(dir string) ([]PackageFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []PackageFile
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		kind, ok := packageFileKinds[filepath.Ext(entry.Name())]
+		if !ok || !matchFile(dir, entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		size, hash, err := hashFile(path)
+		if err != nil {
+			return nil, err
+		}
+		file := PackageFile{Path: path, Kind: kind, Size: size, SHA256: hash}
+		if kind == "assembly" {
+			if file.Symbols, err = ParseAsmFile(path); err != nil {
+				return nil, err
+			}
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// Lists the non-Go files of every dependency. Assembly functions are matched to the Go
+// functions declared without body in the package.
+func InventoryFiles(dependencies []Dependency) ([]DependencyFile, error) {
+	var inventory []DependencyFile
+	for _, dep := range dependencies {
+		files, err := ListPackageFiles(dep.Path)
+		if err != nil {
+			return nil, err
+		}
+		var declarations map[string]string
+		for _, file := range files {
+			if len(file.Symbols) == 0 {
+				continue
+			}
+			if declarations == nil {
+				declarations = parseBodylessFunctions(dep.Path)
+			}
+			matchAsmDeclarations(file.Symbols, declarations)
+		}
+		for _, file := range files {
+			inventory = append(inventory, DependencyFile{
+				ImportPath:    dep.ImportPath,
+				ModulePath:    dep.ModulePath,
+				ModuleVersion: dep.ModuleVersion,
+				Path:          file.Path,
+				Kind:          file.Kind,
+				Size:          file.Size,
+				SHA256:        file.SHA256,
+				Symbols:       file.Symbols,
+			})
+		}
+	}
+	return inventory, nil
+}
+
+func WriteJSONInventory(w io.Writer, inventory []DependencyFile) error {
+	if inventory == nil {
+		inventory = []DependencyFile{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(inventory)
+}
+
+// Parses the Go files of the package in dir, without type-checking, to get the functions declared without body.
+func parseBodylessFunctions(dir string) map[string]string {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range findFiles(".go", dir) {
+		if node, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution); err == nil {
+			files = append(files, node)
+		}
+	}
+	return bodylessFunctions(fset, files)
+}
+
+func hashFile(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
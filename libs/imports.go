@@ -0,0 +1,135 @@
+package libs
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Gets the import paths imported by the non-test Go files of a package.
+func getPackageImports(dep Dependency) []string {
+	files, err := os.ReadDir(dep.Path)
+	if err != nil {
+		return nil
+	}
+
+	var imports []string
+	seen := make(map[string]struct{})
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".go") || strings.HasSuffix(file.Name(), "_test.go") || !matchFile(dep.Path, file.Name()) {
+			continue
+		}
+		node, err := parser.ParseFile(fset, filepath.Join(dep.Path, file.Name()), nil, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		for _, im := range node.Imports {
+			path, err := strconv.Unquote(im.Path.Value)
+			if err != nil {
+				continue
+			}
+			if _, ok := seen[path]; !ok {
+				seen[path] = struct{}{}
+				imports = append(imports, path)
+			}
+		}
+	}
+	return imports
+}
+
+// Gets the import paths of the packages to start the reachability analysis from.
+// Patterns can be import paths or end with "/..." to match a whole subtree.
+// Without patterns, the main packages of the module are used or, for library
+// modules without main packages, all the packages of the module.
+func GetRootPackages(dependencies []Dependency, modulePath string, patterns []string) []string {
+	var roots []string
+	if len(patterns) > 0 {
+		for _, dep := range dependencies {
+			for _, pattern := range patterns {
+				if matchPackagePattern(pattern, dep.ImportPath) {
+					roots = append(roots, dep.ImportPath)
+					break
+				}
+			}
+		}
+		return roots
+	}
+
+	for _, dep := range dependencies {
+		if dep.ModulePath == modulePath && dep.Name == "main" {
+			roots = append(roots, dep.ImportPath)
+		}
+	}
+	if len(roots) == 0 {
+		return GetModulePackages(dependencies, modulePath)
+	}
+	return roots
+}
+
+// Gets the import paths of the packages of the module.
+func GetModulePackages(dependencies []Dependency, modulePath string) []string {
+	var packages []string
+	for _, dep := range dependencies {
+		if dep.ModulePath == modulePath {
+			packages = append(packages, dep.ImportPath)
+		}
+	}
+	return packages
+}
+
+// Walks the transitive import graph from the root packages and returns
+// the set of import paths that are linked into the build.
+func GetReachablePackages(dependencies []Dependency, roots []string) map[string]bool {
+	packages := make(map[string]Dependency)
+	for _, dep := range dependencies {
+		packages[dep.ImportPath] = dep
+	}
+
+	reachable := make(map[string]bool)
+	queue := append([]string(nil), roots...)
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		if reachable[path] {
+			continue
+		}
+		dep, ok := packages[path]
+		if !ok {
+			// Standard library or package not available on disk
+			continue
+		}
+		reachable[path] = true
+		queue = append(queue, getPackageImports(dep)...)
+	}
+	return reachable
+}
+
+// Tags every occurrence as reachable or unreachable. Occurrences in test files are
+// never reachable since test files are not linked into the build.
+func MarkReachable(occurrences []*Occurrence, reachable map[string]bool) {
+	for _, occ := range occurrences {
+		occ.Reachable = reachable[occ.ImportPath] && !strings.HasSuffix(occ.FilePath, "_test.go")
+	}
+}
+
+// Gets the reachable occurrences.
+func FilterReachable(occurrences []*Occurrence) []*Occurrence {
+	var result []*Occurrence
+	for _, occ := range occurrences {
+		if occ.Reachable {
+			result = append(result, occ)
+		}
+	}
+	return result
+}
+
+func matchPackagePattern(pattern string, importPath string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/..."); ok {
+		return importPath == prefix || strings.HasPrefix(importPath, prefix+"/")
+	}
+	return pattern == importPath
+}
@@ -0,0 +1,64 @@
+package libs
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReachablePackages(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"cmd/a/main.go":   "package main\n\nimport (\n\t\"example.com/m/lib\"\n\t\"example.com/x\"\n)\n",
+		"cmd/b/main.go":   "package main\n\nimport \"example.com/y\"\n",
+		"lib/lib.go":      "package lib\n\nimport \"os\"\n",
+		"lib/lib_test.go": "package lib\n\nimport \"example.com/z\"\n",
+		"x/x.go":          "package x\n",
+		"y/y.go":          "package y\n",
+		"z/z.go":          "package z\n",
+	})
+	dependencies := []Dependency{
+		{Name: "main", Path: filepath.Join(dir, "cmd/a"), ImportPath: "example.com/m/cmd/a", ModulePath: "example.com/m"},
+		{Name: "main", Path: filepath.Join(dir, "cmd/b"), ImportPath: "example.com/m/cmd/b", ModulePath: "example.com/m"},
+		{Name: "lib", Path: filepath.Join(dir, "lib"), ImportPath: "example.com/m/lib", ModulePath: "example.com/m"},
+		{Name: "x", Path: filepath.Join(dir, "x"), ImportPath: "example.com/x", ModulePath: "example.com/x"},
+		{Name: "y", Path: filepath.Join(dir, "y"), ImportPath: "example.com/y", ModulePath: "example.com/y"},
+		{Name: "z", Path: filepath.Join(dir, "z"), ImportPath: "example.com/z", ModulePath: "example.com/z"},
+	}
+
+	tests := []struct {
+		patterns []string
+		want     map[string]bool
+	}{
+		{nil, map[string]bool{"example.com/m/cmd/a": true, "example.com/m/cmd/b": true, "example.com/m/lib": true, "example.com/x": true, "example.com/y": true}},
+		{[]string{"example.com/m/cmd/a"}, map[string]bool{"example.com/m/cmd/a": true, "example.com/m/lib": true, "example.com/x": true}},
+		{[]string{"example.com/m/cmd/..."}, map[string]bool{"example.com/m/cmd/a": true, "example.com/m/cmd/b": true, "example.com/m/lib": true, "example.com/x": true, "example.com/y": true}},
+		{[]string{"example.com/m/lib"}, map[string]bool{"example.com/m/lib": true}},
+	}
+	for _, test := range tests {
+		roots := GetRootPackages(dependencies, "example.com/m", test.patterns)
+		if got := GetReachablePackages(dependencies, roots); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.patterns, got, test.want)
+		}
+	}
+}
+
+func TestMarkReachableTestFiles(t *testing.T) {
+	reachable := map[string]bool{"example.com/m/lib": true}
+	tests := []struct {
+		importPath, filePath string
+		want                 bool
+	}{
+		{"example.com/m/lib", "/src/m/lib/lib.go", true},
+		{"example.com/m/lib", "/src/m/lib/lib_test.go", false},
+		{"example.com/m/lib", "/src/m/lib/fixtures_test.go/gen.go", true},
+		{"example.com/z", "/src/z/z.go", false},
+	}
+	for _, test := range tests {
+		occ := &Occurrence{ImportPath: test.importPath, FilePath: test.filePath}
+		MarkReachable([]*Occurrence{occ}, reachable)
+		if occ.Reachable != test.want {
+			t.Errorf("%s: got reachable %v, want %v", test.filePath, occ.Reachable, test.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package libs
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Capabilities recorded in a lockfile, in order.
+var Capabilities = []string{"exec", "network", "fs-write", "unsafe", "cgo", "asm", "plugin", "reflect", "linkname", "init-side-effects"}
+
+// A lockfile records the capabilities used by every module required by the analyzed module,
+// so that an upgrade adding a capability is detected even if the occurrence counts barely change.
+type Lock struct {
+	SchemaVersion string         `json:"SchemaVersion"`
+	Tool          ReportTool     `json:"Tool"`
+	Module        ReportModule   `json:"Module"`
+	Modules       []LockedModule `json:"Modules"`
+}
+
+type LockedModule struct {
+	Path         string   `json:"Path"`
+	Version      string   `json:"Version,omitempty"`
+	Capabilities []string `json:"Capabilities"`
+}
+
+// A module whose capabilities differ from the lockfile.
+type LockChange struct {
+	Path       string
+	OldVersion string // empty for a module that is not in the lockfile
+	NewVersion string
+	Added      []string
+	Removed    []string
+}
+
+// Gets the capability of an occurrence, or an empty string if its attack vector is not a
+// capability (e.g. interfaces or constructors). Raw system calls are counted as unsafe,
+// and prebuilt objects as assembly since both link machine code that has no Go source.
+func OccurrenceCapability(occ *Occurrence) string {
+	switch occ.AttackVector {
+	case "exec":
+		return "exec"
+	case "network":
+		return "network"
+	case "filesystem":
+		if occ.Kind == "write" || occ.Kind == "delete" {
+			return "fs-write"
+		}
+	case "unsafe", "syscall":
+		return "unsafe"
+	case "cgo":
+		if occ.Kind == "exec" {
+			return "exec"
+		}
+		return "cgo"
+	case "assembly", "object":
+		return "asm"
+	case "plugin":
+		return "plugin"
+	case "reflect":
+		return "reflect"
+	case "linkname":
+		return "linkname"
+	case "importtime":
+		return "init-side-effects"
+	}
+	return ""
+}
+
+// Builds the lockfile of a module from its required modules and their occurrences. Required
+// modules without occurrences are recorded with no capability. Occurrences of the module
+// itself and of test files are not recorded.
+func NewLock(module Module, required []Module, occurrences []*Occurrence) Lock {
+	lock := Lock{
+		SchemaVersion: ReportSchemaVersion,
+		Tool:          ReportTool{Name: ToolName, Version: ToolVersion},
+		Module:        ReportModule{Path: module.Path, Version: module.Version},
+		Modules:       []LockedModule{},
+	}
+
+	capabilities := make(map[string]map[string]bool)
+	versions := make(map[string]string)
+	for _, mod := range required {
+		capabilities[mod.Path] = make(map[string]bool)
+		versions[mod.Path] = mod.Version
+	}
+	for _, occ := range occurrences {
+		if occ.ModulePath == "" || occ.ModulePath == module.Path || strings.HasSuffix(occ.FilePath, "_test.go") {
+			continue
+		}
+		if _, ok := capabilities[occ.ModulePath]; !ok {
+			capabilities[occ.ModulePath] = make(map[string]bool)
+		}
+		versions[occ.ModulePath] = occ.ModuleVersion
+		if capability := OccurrenceCapability(occ); capability != "" {
+			capabilities[occ.ModulePath][capability] = true
+		}
+	}
+
+	for path, used := range capabilities {
+		locked := LockedModule{Path: path, Version: versions[path], Capabilities: []string{}}
+		for _, capability := range Capabilities {
+			if used[capability] {
+				locked.Capabilities = append(locked.Capabilities, capability)
+			}
+		}
+		lock.Modules = append(lock.Modules, locked)
+	}
+	sort.Slice(lock.Modules, func(i, j int) bool { return lock.Modules[i].Path < lock.Modules[j].Path })
+	return lock
+}
+
+func ReadLock(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lock Lock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+func WriteLock(path string, lock Lock) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Compares the current capabilities of the modules to the lockfile. Modules are matched by
+// path, so that an upgrade is compared to the locked version. Modules that were removed
+// are not reported.
+func (lock *Lock) Compare(current Lock) []LockChange {
+	locked := make(map[string]LockedModule)
+	for _, mod := range lock.Modules {
+		locked[mod.Path] = mod
+	}
+
+	var changes []LockChange
+	for _, mod := range current.Modules {
+		old, found := locked[mod.Path]
+		change := LockChange{Path: mod.Path, OldVersion: old.Version, NewVersion: mod.Version}
+		for _, capability := range mod.Capabilities {
+			if !slices.Contains(old.Capabilities, capability) {
+				change.Added = append(change.Added, capability)
+			}
+		}
+		for _, capability := range old.Capabilities {
+			if !slices.Contains(mod.Capabilities, capability) {
+				change.Removed = append(change.Removed, capability)
+			}
+		}
+		if !found || len(change.Added) > 0 || len(change.Removed) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
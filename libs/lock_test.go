@@ -0,0 +1,36 @@
+package libs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewLock(t *testing.T) {
+	module := Module{Path: "example.com/m"}
+	required := []Module{
+		{Path: "example.com/b", Version: "v1.0.0"},
+		{Path: "example.com/a", Version: "v0.2.0"},
+	}
+	occurrences := []*Occurrence{
+		{AttackVector: "exec", ModulePath: "example.com/a", ModuleVersion: "v0.2.0", FilePath: "a.go"},
+		{AttackVector: "network", ModulePath: "example.com/a", ModuleVersion: "v0.2.0", FilePath: "a_test.go"},
+		{AttackVector: "exec", ModulePath: "example.com/m", FilePath: "m.go"},
+	}
+
+	lock := NewLock(module, required, occurrences)
+	want := []LockedModule{
+		{Path: "example.com/a", Version: "v0.2.0", Capabilities: []string{"exec"}},
+		{Path: "example.com/b", Version: "v1.0.0", Capabilities: []string{}},
+	}
+	if !reflect.DeepEqual(lock.Modules, want) {
+		t.Errorf("got %v, want %v", lock.Modules, want)
+	}
+
+	// b starts using the network: it is reported although it had no capability
+	occurrences = append(occurrences, &Occurrence{AttackVector: "network", ModulePath: "example.com/b", ModuleVersion: "v1.1.0", FilePath: "b.go"})
+	changes := lock.Compare(NewLock(module, required, occurrences))
+	wantChanges := []LockChange{{Path: "example.com/b", OldVersion: "v1.0.0", NewVersion: "v1.1.0", Added: []string{"network"}}}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("got changes %v, want %v", changes, wantChanges)
+	}
+}
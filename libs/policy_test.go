@@ -0,0 +1,35 @@
+package libs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadPolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		err    string
+	}{
+		{`{"rules": [{"vector": "unsafe", "action": "allow", "modules": ["golang.org/x/sys"]}]}`, ""},
+		{`{"rules": [{"vector": "exec", "action": "deny", "scope": "third-party"}]}`, ""},
+		{`{"rules": [{"vector": "unsafe", "action": "allow"}]}`, "rule 1: action allow requires modules"},
+		{`{"rules": [{"vector": "unsafe", "action": "allow", "modules": []}]}`, "rule 1: action allow requires modules"},
+		{`{"rules": [{"vector": "exec", "action": "permit"}]}`, "rule 1: unknown action"},
+		{`{"rules": [{"vector": "nope", "action": "deny"}]}`, "rule 1: unknown attack vector"},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "policy.json")
+		if err := os.WriteFile(path, []byte(test.policy), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ReadPolicy(path)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", test.policy, err)
+		case test.err != "" && (err == nil || !strings.HasPrefix(err.Error(), test.err)):
+			t.Errorf("%s: got error %v, want %q", test.policy, err, test.err)
+		}
+	}
+}
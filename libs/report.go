@@ -0,0 +1,76 @@
+package libs
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const (
+	ToolName    = "GoSurf"
+	ToolVersion = "0.2.0"
+
+	// Version of the JSON report format, bumped on incompatible changes
+	ReportSchemaVersion = "1"
+)
+
+type Report struct {
+	SchemaVersion string           `json:"SchemaVersion"`
+	Tool          ReportTool       `json:"Tool"`
+	Module        ReportModule     `json:"Module"`
+	Counts        []VectorCount    `json:"Counts"`
+	Occurrences   []OccurrenceJSON `json:"Occurrences"`
+}
+
+type ReportTool struct {
+	Name    string `json:"Name"`
+	Version string `json:"Version"`
+}
+
+type ReportModule struct {
+	Path    string `json:"Path"`
+	Version string `json:"Version,omitempty"`
+}
+
+type VectorCount struct {
+	ID     string         `json:"ID"`
+	Vector string         `json:"Vector"`
+	Title  string         `json:"Title"`
+	Count  int            `json:"Count"`
+	Kinds  map[string]int `json:"Kinds,omitempty"` // unique occurrences per sub-kind, e.g. include for cgo
+}
+
+// Builds the report of the occurrences found in the analyzed module.
+// Counts are unique occurrences, as in CountUniqueOccurrences.
+func NewReport(module Module, occurrences []*Occurrence) Report {
+	report := Report{
+		SchemaVersion: ReportSchemaVersion,
+		Tool:          ReportTool{Name: ToolName, Version: ToolVersion},
+		Module:        ReportModule{Path: module.Path, Version: module.Version},
+		Occurrences:   make([]OccurrenceJSON, 0, len(occurrences)),
+	}
+
+	counts := CountOccurrencesByVector(occurrences)
+	kinds := CountOccurrencesByKind(occurrences)
+	for _, vector := range AttackVectors {
+		report.Counts = append(report.Counts, VectorCount{
+			ID:     vector.ID,
+			Vector: vector.Name,
+			Title:  vector.Title,
+			Count:  counts[vector.Name],
+			Kinds:  kinds[vector.Name],
+		})
+	}
+
+	for _, occ := range occurrences {
+		report.Occurrences = append(report.Occurrences, toOccurrenceJSON(occ))
+	}
+	return report
+}
+
+// Writes the report as an indented JSON document.
+func WriteJSONReport(w io.Writer, report Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(report)
+}
@@ -0,0 +1,99 @@
+package libs
+
+import (
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"slices"
+)
+
+// Recognizes a call producing data to track, e.g. a decoding function, and gets the name of
+// the source, or an empty string.
+type taintSource func(info *types.Info, call *ast.CallExpr) string
+
+// Adds to tainted the variables of the package assigned from expressions using tainted data
+// (see taintOf), until no new variable is found. Objects are mapped to the name of their source.
+// Data is only tracked through assignments, not through function calls or fields.
+func propagateTaint(pkg *TypedPackage, tainted map[types.Object]string, source taintSource) {
+	for changed := true; changed; {
+		changed = false
+		taint := func(lhs []ast.Expr, rhs []ast.Expr) {
+			for _, expr := range rhs {
+				name := taintOf(pkg.Info, expr, tainted, source)
+				if name == "" {
+					continue
+				}
+				for _, target := range lhs {
+					ident, ok := ast.Unparen(target).(*ast.Ident)
+					if !ok {
+						continue
+					}
+					obj := pkg.Info.ObjectOf(ident)
+					if _, known := tainted[obj]; obj != nil && !known {
+						tainted[obj] = name
+						changed = true
+					}
+				}
+			}
+		}
+		for _, file := range pkg.Files {
+			ast.Inspect(file.Ast, func(n ast.Node) bool {
+				switch x := n.(type) {
+				case *ast.AssignStmt:
+					taint(x.Lhs, x.Rhs)
+				case *ast.ValueSpec:
+					var lhs []ast.Expr
+					for _, name := range x.Names {
+						lhs = append(lhs, name)
+					}
+					taint(lhs, x.Values)
+				}
+				return true
+			})
+		}
+	}
+}
+
+// Gets the source of the tainted data used by an expression, or an empty string: a tainted
+// variable, or a call recognized by source (which can be nil).
+func taintOf(info *types.Info, expr ast.Expr, tainted map[types.Object]string, source taintSource) string {
+	name := ""
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if name != "" {
+			return false
+		}
+		switch x := n.(type) {
+		case *ast.Ident:
+			name = tainted[info.Uses[x]]
+		case *ast.CallExpr:
+			if source != nil {
+				name = source(info, x)
+			}
+		}
+		return name == ""
+	})
+	return name
+}
+
+// Gets the name of a call that runs or writes its arguments: exec functions, os.WriteFile,
+// ioutil.WriteFile, writes to an *os.File and plugin.Open.
+func dataSink(info *types.Info, call *ast.CallExpr) (string, bool) {
+	if pkgPath, funcName, ok := resolvePackageSelector(info, call.Fun); ok {
+		for _, execFunc := range execFuncs {
+			if pkgPath == execFunc.pkgPath && slices.Contains(execFunc.funcNames, funcName) {
+				return filepath.Base(pkgPath) + "." + funcName, true
+			}
+		}
+		switch pkgPath + "." + funcName {
+		case "os.WriteFile", "io/ioutil.WriteFile", "plugin.Open":
+			return filepath.Base(pkgPath) + "." + funcName, true
+		}
+		return "", false
+	}
+	if pkgPath, recv, funcName, ok := resolveCallee(info, call); ok && pkgPath == "os" && recv == "File" {
+		if funcName == "Write" || funcName == "WriteString" || funcName == "WriteAt" {
+			return "os.File." + funcName, true
+		}
+	}
+	return "", false
+}
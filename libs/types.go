@@ -0,0 +1,229 @@
+package libs
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/constant"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A package parsed with comments and type-checked with go/types.
+type TypedPackage struct {
+	Dir          string
+	Fset         *token.FileSet
+	Files        []*SourceFile // sorted by path
+	Info         *types.Info
+	AsmFunctions []AsmFunction // functions implemented in assembly files of the package
+	OtherFiles   []PackageFile // non-Go files picked up by the go tool
+	Dependency   Dependency    // set by AnalyzePackage
+}
+
+// A Go source file parsed once and shared by all the parsers.
+type SourceFile struct {
+	Path       string
+	Content    []byte
+	Ast        *ast.File
+	Fset       *token.FileSet
+	Info       *types.Info
+	Package    *TypedPackage
+	Constraint string // build constraint of the file, see fileConstraint
+}
+
+// Gets a line of the file, with normalized white space. Lines are found with the line
+// table of the parsed file, so the content is not split again for every line.
+func (file *SourceFile) Line(line int) string {
+	tokFile := file.Fset.File(file.Ast.Pos())
+	if tokFile == nil || line < 1 || line > tokFile.LineCount() {
+		return ""
+	}
+	start, end := tokFile.Offset(tokFile.LineStart(line)), len(file.Content)
+	if line < tokFile.LineCount() {
+		end = tokFile.Offset(tokFile.LineStart(line + 1))
+	}
+	return strings.Join(strings.Fields(string(file.Content[start:end])), " ")
+}
+
+// Imports standard library packages from source. Other imports are not resolved:
+// go/types then uses a fake package that still records the real import path,
+// which is all the parsers need to recognize a selector.
+type stdImporter struct {
+	mu       sync.Mutex
+	importer types.ImporterFrom
+}
+
+var sharedImporter = &stdImporter{
+	importer: importer.ForCompiler(token.NewFileSet(), "source", nil).(types.ImporterFrom),
+}
+
+func (imp *stdImporter) Import(path string) (*types.Package, error) {
+	return imp.ImportFrom(path, "", 0)
+}
+
+func (imp *stdImporter) ImportFrom(path string, dir string, mode types.ImportMode) (*types.Package, error) {
+	if !isStdPackage(path) {
+		return nil, fmt.Errorf("package %s is not in the standard library", path)
+	}
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	return imp.importer.ImportFrom(path, dir, mode)
+}
+
+func isStdPackage(path string) bool {
+	if path == "unsafe" {
+		return true
+	}
+	firstElem, _, _ := strings.Cut(path, "/")
+	if strings.Contains(firstElem, ".") {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(build.Default.GOROOT, "src", filepath.FromSlash(path)))
+	return err == nil && info.IsDir()
+}
+
+// Parses all Go files in dir and type-checks them. Files of different packages
+// (e.g. external test packages) are checked separately into the same types.Info.
+// Type errors are tolerated: the information that could be resolved is kept.
+func LoadPackage(dir string) (*TypedPackage, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &TypedPackage{
+		Dir:  dir,
+		Fset: token.NewFileSet(),
+		Info: &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		},
+	}
+
+	filesByPackage := make(map[string][]*ast.File)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") || !matchFile(dir, entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", path, err)
+			continue
+		}
+		node, err := parser.ParseFile(pkg.Fset, path, content, parser.ParseComments|parser.AllErrors)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing file %s: %v\n", path, err)
+			continue
+		}
+		pkg.Files = append(pkg.Files, &SourceFile{Path: path, Content: content, Ast: node, Fset: pkg.Fset, Info: pkg.Info, Package: pkg, Constraint: fileConstraint(entry.Name(), content)})
+		filesByPackage[node.Name.Name] = append(filesByPackage[node.Name.Name], node)
+	}
+
+	names := make([]string, 0, len(filesByPackage))
+	for name := range filesByPackage {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		conf := types.Config{
+			Importer:    sharedImporter,
+			FakeImportC: true,
+			Error:       func(error) {}, // keep going on type errors
+		}
+		conf.Check(name, pkg.Fset, filesByPackage[name], pkg.Info)
+	}
+
+	if pkg.OtherFiles, err = ListPackageFiles(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing files of %s: %v\n", dir, err)
+	}
+	for _, other := range pkg.OtherFiles {
+		pkg.AsmFunctions = append(pkg.AsmFunctions, other.Symbols...)
+	}
+	if len(pkg.AsmFunctions) > 0 {
+		var asts []*ast.File
+		for _, file := range pkg.Files {
+			asts = append(asts, file.Ast)
+		}
+		matchAsmDeclarations(pkg.AsmFunctions, bodylessFunctions(pkg.Fset, asts))
+	}
+
+	return pkg, nil
+}
+
+// Resolves a selector expression of the form pkg.Name, where pkg is an imported
+// package, to the import path of the package and the selected name.
+// Renamed imports are followed, and local identifiers shadowing a package are rejected.
+func resolvePackageSelector(info *types.Info, expr ast.Expr) (pkgPath string, name string, ok bool) {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return "", "", false
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "", "", false
+	}
+	pkgName, ok := info.Uses[ident].(*types.PkgName)
+	if !ok {
+		return "", "", false
+	}
+	return pkgName.Imported().Path(), sel.Sel.Name, true
+}
+
+// Gets the object denoted by the callee of a call expression, if resolved.
+func calleeObject(info *types.Info, call *ast.CallExpr) types.Object {
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		return info.Uses[fun]
+	case *ast.SelectorExpr:
+		return info.Uses[fun.Sel]
+	}
+	return nil
+}
+
+// Gets the package path, receiver type name (empty for functions) and name of the
+// function or method called by a call expression, if resolved.
+func resolveCallee(info *types.Info, call *ast.CallExpr) (pkgPath string, recv string, name string, ok bool) {
+	fn, ok := calleeObject(info, call).(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return "", "", "", false
+	}
+	if sig, ok := fn.Type().(*types.Signature); ok && sig.Recv() != nil {
+		recvType := sig.Recv().Type()
+		if ptr, ok := recvType.(*types.Pointer); ok {
+			recvType = ptr.Elem()
+		}
+		named, ok := recvType.(*types.Named)
+		if !ok {
+			return "", "", "", false
+		}
+		recv = named.Obj().Name()
+	}
+	return fn.Pkg().Path(), recv, fn.Name(), true
+}
+
+// Gets the value of a constant string expression, e.g. a literal or a named constant.
+func constString(info *types.Info, expr ast.Expr) (string, bool) {
+	tv, ok := info.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
+
+// Reports whether the call expression is a type conversion, e.g. int64(x).
+func isConversion(info *types.Info, call *ast.CallExpr) bool {
+	tv, ok := info.Types[call.Fun]
+	return ok && tv.IsType()
+}
@@ -0,0 +1,161 @@
+package libs
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Unicode bidirectional control characters, which reorder the displayed text (Trojan Source, CVE-2021-42574)
+var bidiControls = map[rune]string{
+	'\u061C': "ARABIC LETTER MARK",
+	'\u200E': "LEFT-TO-RIGHT MARK",
+	'\u200F': "RIGHT-TO-LEFT MARK",
+	'\u202A': "LEFT-TO-RIGHT EMBEDDING",
+	'\u202B': "RIGHT-TO-LEFT EMBEDDING",
+	'\u202C': "POP DIRECTIONAL FORMATTING",
+	'\u202D': "LEFT-TO-RIGHT OVERRIDE",
+	'\u202E': "RIGHT-TO-LEFT OVERRIDE",
+	'\u2066': "LEFT-TO-RIGHT ISOLATE",
+	'\u2067': "RIGHT-TO-LEFT ISOLATE",
+	'\u2068': "FIRST STRONG ISOLATE",
+	'\u2069': "POP DIRECTIONAL ISOLATE",
+}
+
+// Invisible characters, which hide text or make two strings that look equal differ
+var invisibleChars = map[rune]string{
+	'\u00AD': "SOFT HYPHEN",
+	'\u180E': "MONGOLIAN VOWEL SEPARATOR",
+	'\u200B': "ZERO WIDTH SPACE",
+	'\u200C': "ZERO WIDTH NON-JOINER",
+	'\u200D': "ZERO WIDTH JOINER",
+	'\u2060': "WORD JOINER",
+	'\u2062': "INVISIBLE TIMES",
+	'\u2063': "INVISIBLE SEPARATOR",
+	'\u2064': "INVISIBLE PLUS",
+	'\uFEFF': "ZERO WIDTH NO-BREAK SPACE",
+}
+
+// Non-ASCII letters that look like ASCII letters (a subset of the Unicode confusables)
+var asciiConfusables = map[rune]rune{
+	// Cyrillic
+	'\u0430': 'a', '\u0432': 'b', '\u0435': 'e', '\u043A': 'k', '\u043C': 'm', '\u043D': 'h', '\u043E': 'o', '\u0440': 'p', '\u0441': 'c', '\u0442': 't', '\u0443': 'y', '\u0445': 'x',
+	'\u0455': 's', '\u0456': 'i', '\u0458': 'j', '\u04BB': 'h', '\u0501': 'd', '\u051B': 'q', '\u051D': 'w', '\u04CF': 'l',
+	'\u0410': 'A', '\u0412': 'B', '\u0415': 'E', '\u041A': 'K', '\u041C': 'M', '\u041D': 'H', '\u041E': 'O', '\u0420': 'P', '\u0421': 'C', '\u0422': 'T', '\u0425': 'X',
+	'\u0405': 'S', '\u0406': 'I', '\u0408': 'J', '\u051A': 'Q', '\u051C': 'W', '\u04AE': 'Y',
+	// Greek
+	'\u03B1': 'a', '\u03BF': 'o', '\u03C1': 'p', '\u03BD': 'v', '\u03B9': 'i', '\u03BA': 'k', '\u03C4': 't', '\u03C5': 'u', '\u03C7': 'x',
+	'\u0391': 'A', '\u0392': 'B', '\u0395': 'E', '\u0396': 'Z', '\u0397': 'H', '\u0399': 'I', '\u039A': 'K', '\u039C': 'M', '\u039D': 'N', '\u039F': 'O', '\u03A1': 'P', '\u03A4': 'T', '\u03A5': 'Y', '\u03A7': 'X',
+	// Latin look-alikes outside ASCII
+	'\u0131': 'i', '\u0237': 'j', '\u0251': 'a', '\u0261': 'g', '\u2113': 'l',
+}
+
+// Gets the name of the script of a letter, e.g. Latin or Cyrillic, or an empty string
+// for characters common to all scripts (digits, underscore).
+func letterScript(r rune) string {
+	if r < unicode.MaxASCII {
+		if unicode.IsLetter(r) {
+			return "Latin"
+		}
+		return ""
+	}
+	for _, name := range []string{"Latin", "Cyrillic", "Greek", "Armenian", "Cherokee", "Coptic"} {
+		if unicode.Is(unicode.Scripts[name], r) {
+			return name
+		}
+	}
+	// scripts are sorted to make the result deterministic
+	names := make([]string, 0, len(unicode.Scripts))
+	for name := range unicode.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name != "Common" && name != "Inherited" && unicode.Is(unicode.Scripts[name], r) {
+			return name
+		}
+	}
+	return ""
+}
+
+// Gets the scripts of the letters of an identifier, in order of appearance.
+func identifierScripts(name string) []string {
+	var scripts []string
+	for _, r := range name {
+		if script := letterScript(r); script != "" && !slices.Contains(scripts, script) {
+			scripts = append(scripts, script)
+		}
+	}
+	return scripts
+}
+
+// Gets the ASCII identifier that an identifier with confusable letters looks like,
+// e.g. exec for exec spelled with a Cyrillic e, or an empty string if it has other non-ASCII characters.
+func asciiSkeleton(name string) string {
+	var skeleton strings.Builder
+	confusable := false
+	for _, r := range name {
+		if r < unicode.MaxASCII {
+			skeleton.WriteRune(r)
+			continue
+		}
+		ascii, ok := asciiConfusables[r]
+		if !ok {
+			return ""
+		}
+		skeleton.WriteRune(ascii)
+		confusable = true
+	}
+	if !confusable {
+		return ""
+	}
+	return skeleton.String()
+}
+
+// Gets the ASCII identifiers that an identifier with confusable letters can impersonate:
+// the identifiers used in the files of the package and the predeclared ones, e.g. nil.
+func asciiIdentifiers(files []*SourceFile) map[string]bool {
+	identifiers := make(map[string]bool)
+	for _, name := range types.Universe.Names() {
+		identifiers[name] = true
+	}
+	for _, file := range files {
+		ast.Inspect(file.Ast, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && isASCII(ident.Name) {
+				identifiers[ident.Name] = true
+			}
+			return true
+		})
+	}
+	return identifiers
+}
+
+// Gets the context of a byte offset in a file: comment, string or code.
+func sourceContext(node *ast.File, tokFile *token.File, offset int) string {
+	pos := tokFile.Pos(offset)
+	for _, cg := range node.Comments {
+		if cg.Pos() <= pos && pos < cg.End() {
+			return "comment"
+		}
+	}
+	context := "code"
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil || context != "code" || pos < n.Pos() || pos >= n.End() {
+			return false
+		}
+		if lit, ok := n.(*ast.BasicLit); ok && (lit.Kind == token.STRING || lit.Kind == token.CHAR) {
+			context = "string"
+		}
+		return true
+	})
+	return context
+}
+
+func formatRune(r rune, name string) string {
+	return fmt.Sprintf("U+%04X %s", r, name)
+}
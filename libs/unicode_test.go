@@ -0,0 +1,43 @@
+package libs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrojanSourceIdentifiers(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"m.go": `package m
+
+import "os/exec"
+
+func ехес() {} // Cyrillic е, х, с: looks like the exec package
+
+func run() {
+	α := 1.0    // Greek
+	счёт := 2.0 // Cyrillic
+	рос := 3.0  // Cyrillic, looks like poc, which is not used
+	_, _, _ = α, счёт, рос
+	ехес()
+	еxec := exec.Command // Cyrillic е and Latin xec
+	еxecute := 4 // Cyrillic е, not confusable with a used identifier
+	_, _ = еxec, еxecute
+}
+`})
+
+	dep := Dependency{Name: "m", Path: dir, ImportPath: "example.com/m"}
+	occurrences := AnalyzeDependencies([]Dependency{dep}, 1, TrojanSourceParser{})
+
+	got := make(map[string]string)
+	for _, occ := range occurrences {
+		got[occ.MethodInvoked] = occ.Kind
+	}
+	want := map[string]string{
+		"ехес":    "confusable",
+		"еxec":    "confusable",
+		"еxecute": "mixed-script",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -3,60 +3,104 @@ package libs
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"reflect"
 	"regexp"
 	"strings"
+	"unicode"
 )
 
 type Occurrence struct {
-	PackageName   string
-	AttackVector  string
-	FilePath      string
-	LineNumber    int
-	VariableName  string // for anonymous functions
-	Command       string // for go:generate directive
-	MethodInvoked string // for interface, exec, plugin, cgo
-	TypePassed    string // for interface
-	Pattern       string // for constructors
+	PackageName     string
+	AttackVector    string
+	FilePath        string
+	LineNumber      int
+	ColumnNumber    int
+	VariableName    string   // for anonymous functions, local symbol for linkname
+	Command         string   // for go:generate and #cgo directives
+	MethodInvoked   string   // for interface, exec, plugin, cgo, target symbol for linkname
+	TypePassed      string   // for interface
+	Pattern         string   // for constructors, embed pattern for embed
+	TargetPackage   string   // for linkname
+	Kind            string   // sub-category of the occurrence, see the attack vector in AttackVectors
+	Literals        []string // constant values found at the occurrence, see the attack vector in AttackVectors
+	Severity        string   // overrides the severity of the attack vector, e.g. high for secrets
+	BuildConstraint string   // build constraint guarding the file, e.g. linux && amd64
+	UnusualTags     []string // tags of the build constraint unknown to the go tool, e.g. ignore
+	UnsafeImported  bool     // for linkname, required by the compiler for go:linkname
+	ModulePath      string
+	ModuleVersion   string
+	ImportPath      string   // import path of the package
+	Reachable       bool     // package is linked into the build (see MarkReachable)
+	Function        string   // enclosing function, empty at package level
+	Snippet         string   // source line, with normalized white space
+	CallChain       []string // for importtime, functions called from the initializer down to the operation
+	CallPath        []string // functions called from an entry point of the module (see CallGraph.MarkReachable)
 }
 
 type OccurrenceJSON struct {
-	PackageName   string `json:"PackageName,omitempty"`
-	Type          string `json:"Type,omitempty"`
-	FilePath      string `json:"FilePath,omitempty"`
-	LineNumber    int    `json:"LineNumber,omitempty"`
-	MethodInvoked string `json:"MethodInvoked,omitempty"`
-	TypePassed    string `json:"TypePassed,omitempty"`
-	VariableName  string `json:"VariableName,omitempty"`
-	Command       string `json:"Command,omitempty"`
-	Pattern       string `json:"Pattern,omitempty"`
+	PackageName     string   `json:"PackageName,omitempty"`
+	Type            string   `json:"Type,omitempty"`
+	FilePath        string   `json:"FilePath,omitempty"`
+	LineNumber      int      `json:"LineNumber,omitempty"`
+	ColumnNumber    int      `json:"ColumnNumber,omitempty"`
+	MethodInvoked   string   `json:"MethodInvoked,omitempty"`
+	TypePassed      string   `json:"TypePassed,omitempty"`
+	VariableName    string   `json:"VariableName,omitempty"`
+	Command         string   `json:"Command,omitempty"`
+	Pattern         string   `json:"Pattern,omitempty"`
+	TargetPackage   string   `json:"TargetPackage,omitempty"`
+	Kind            string   `json:"Kind,omitempty"`
+	Literals        []string `json:"Literals,omitempty"`
+	Severity        string   `json:"Severity,omitempty"`
+	BuildConstraint string   `json:"BuildConstraint,omitempty"`
+	UnusualTags     []string `json:"UnusualTags,omitempty"`
+	UnsafeImported  bool     `json:"UnsafeImported,omitempty"`
+	ModulePath      string   `json:"ModulePath,omitempty"`
+	ModuleVersion   string   `json:"ModuleVersion,omitempty"`
+	ImportPath      string   `json:"ImportPath,omitempty"`
+	Reachable       bool     `json:"Reachable,omitempty"`
+	Function        string   `json:"Function,omitempty"`
+	Snippet         string   `json:"Snippet,omitempty"`
+	CallChain       []string `json:"CallChain,omitempty"`
+	CallPath        []string `json:"CallPath,omitempty"`
+	Fingerprint     string   `json:"Fingerprint,omitempty"`
 }
 
 type Dependency struct {
-	Name string
-	Path string
+	Name          string
+	Path          string
+	ImportPath    string
+	ModulePath    string
+	ModuleVersion string
 }
 
 type OccurrenceParser interface {
-	FindOccurrences(path string, packageName string, occurrences *[]*Occurrence)
+	FindOccurrences(file *SourceFile, packageName string, occurrences *[]*Occurrence)
 }
 
-var pkgAsmFunctions []string
+// Implemented by parsers that also analyze the package as a whole, e.g. its non-Go files
+// or data flows across its files. FindPackageOccurrences is called once per package,
+// after FindOccurrences has been called with each file.
+type PackageParser interface {
+	FindPackageOccurrences(pkg *TypedPackage, packageName string, occurrences *[]*Occurrence)
+}
 
 // Gets all go files in given path.
 func GetDependencies(modulePath string) ([]Dependency, error) { // TODO should rename this one. If getting dependencies, we look at the go.mod file.
 
 	var dependencies []Dependency
+	mod := GetModuleInfo(modulePath)
 
 	// Check if the parent folder is a package
 	isPackage, packageName, packagePath := isGoPackage(modulePath)
 	if isPackage {
 		//canBuild, _ := canBuildGoPackage(modulePath)
 		//if canBuild {
-		dependency := Dependency{Name: packageName, Path: packagePath}
+		dependency := Dependency{Name: packageName, Path: packagePath, ImportPath: mod.Path, ModulePath: mod.Path, ModuleVersion: mod.Version}
 		dependencies = append(dependencies, dependency)
 		//}
 	}
@@ -85,7 +129,7 @@ func GetDependencies(modulePath string) ([]Dependency, error) { // TODO should r
 		if isPackage {
 			//canBuild, _ := canBuildGoPackage(dirPath)
 			//if canBuild {
-			dependency := Dependency{Name: packageName, Path: packagePath}
+			dependency := Dependency{Name: packageName, Path: packagePath, ImportPath: packageImportPath(mod, dirPath), ModulePath: mod.Path, ModuleVersion: mod.Version}
 			dependencies = append(dependencies, dependency)
 			//}
 		}
@@ -165,37 +209,6 @@ func findFiles(suffix string, dirPath string) []string {
 	return files
 }
 
-func pkgContainsAsm(dirPath string) (bool, []string) {
-	var asmSuffixes = []string{".s", ".S", ".sx"}
-	var files []string
-
-	for _, suffix := range asmSuffixes {
-		files = append(files, findFiles(suffix, dirPath)...)
-	}
-	if len(files) == 0 {
-		// No assembly files in package
-		return false, nil
-	}
-
-	// Find all assembly function signatures in pkg ('TEXT ·' pattern)
-	var signatureRegex = regexp.MustCompile(`TEXT\s+·[A-Za-z1-9]+\w*`)
-	var signatures []string
-
-	for _, file := range files {
-		filePath := filepath.Join(dirPath, file)
-		content, err := os.ReadFile(filePath)
-		if err != nil {
-			continue
-		}
-		match := signatureRegex.FindString(string(content))
-		if match != "" {
-			funSig := strings.Split(match, "·")[1]
-			signatures = append(signatures, funSig)
-		}
-	}
-	return true, signatures
-}
-
 func GetLineColumn(content []byte, index int) (line, col int) {
 	line = 1
 	col = 1
@@ -212,108 +225,179 @@ func GetLineColumn(content []byte, index int) (line, col int) {
 	return line, col
 }
 
-func AnalyzePackage(dep Dependency, occurrences *[]*Occurrence, parser OccurrenceParser) {
-	files, err := os.ReadDir(dep.Path)
+// Parses and type-checks each file of the package once, and runs all the parsers over it.
+func AnalyzePackage(dep Dependency, occurrences *[]*Occurrence, parsers ...OccurrenceParser) {
+	pkg, err := LoadPackage(dep.Path)
 	if err != nil {
-		fmt.Printf("Error accessing directory %s: %v\n", dep.Path, err)
+		fmt.Fprintf(os.Stderr, "Error accessing directory %s: %v\n", dep.Path, err)
 		return
 	}
+	pkg.Dependency = dep
 
-	// If assembly parser, get set the assembly function definitions in the package
-	currentParser := reflect.TypeOf(parser)
-	asmParser := reflect.TypeOf(AssemblyParser{})
+	firstNew := len(*occurrences)
+	for _, file := range pkg.Files {
+		firstInFile := len(*occurrences)
+		for _, parser := range parsers {
+			parser.FindOccurrences(file, dep.Name, occurrences)
+		}
+		annotateOccurrences(file, (*occurrences)[firstInFile:])
+		annotateConstraints(file, (*occurrences)[firstInFile:])
+	}
 
-	if currentParser == asmParser {
-		ok, funSigs := pkgContainsAsm(dep.Path)
-		pkgAsmFunctions = funSigs
-		if !ok {
-			// Avoid running assembly parser in package without assembly
-			return
+	firstInPackage := len(*occurrences)
+	for _, parser := range parsers {
+		if pkgParser, ok := parser.(PackageParser); ok {
+			pkgParser.FindPackageOccurrences(pkg, dep.Name, occurrences)
 		}
 	}
+	annotatePackageOccurrences(pkg, (*occurrences)[firstInPackage:])
 
-	for _, file := range files {
-		if file.IsDir() || !strings.HasSuffix(file.Name(), ".go") {
-			continue
+	// Attach the module the package belongs to
+	for _, occ := range (*occurrences)[firstNew:] {
+		occ.ModulePath = dep.ModulePath
+		occ.ModuleVersion = dep.ModuleVersion
+		occ.ImportPath = dep.ImportPath
+	}
+}
+
+// Annotates occurrences found in the whole package like those of the Go file they are in.
+func annotatePackageOccurrences(pkg *TypedPackage, occurrences []*Occurrence) {
+	files := make(map[string]*SourceFile)
+	for _, file := range pkg.Files {
+		files[file.Path] = file
+	}
+	for _, occ := range occurrences {
+		if file, ok := files[occ.FilePath]; ok {
+			annotateOccurrences(file, []*Occurrence{occ})
+			annotateConstraints(file, []*Occurrence{occ})
+		} else {
+			// e.g. assembly or object files of the package, annotated by their parser
+			occ.BuildConstraint = pathConstraint(occ.FilePath)
+			occ.UnusualTags = unusualTags(occ.BuildConstraint)
+		}
+	}
+}
+
+// Gets the occurrences of the given attack vector.
+func FilterOccurrences(occurrences []*Occurrence, attackVector string) []*Occurrence {
+	var result []*Occurrence
+	for _, occ := range occurrences {
+		if occ.AttackVector == attackVector {
+			result = append(result, occ)
 		}
-		path := filepath.Join(dep.Path, file.Name())
-		parser.FindOccurrences(path, dep.Name, occurrences)
 	}
+	return result
 }
 
 func CountUniqueOccurrences(occurrences []*Occurrence) (initCount, anonymCount, execCount, pluginCount, goGenerateCount, goTestCount, unsafeCount, cgoCount, interfaceCount, reflectCount, constructorCount, assemblyCount int) {
-	initOccurrences := make(map[string]struct{})
-	globalVarOccurrences := make(map[string]struct{})
-	execOccurrences := make(map[string]struct{})
-	pluginOccurrences := make(map[string]struct{})
-	goGenerateOccurrences := make(map[string]struct{})
-	goTestOccurrences := make(map[string]struct{})
-	unsafeOccurrences := make(map[string]struct{})
-	cgoOccurrences := make(map[string]struct{})
-	interfaceOccurrences := make(map[string]struct{})
-	reflectOccurrences := make(map[string]struct{})
-	constructorOccurrences := make(map[string]struct{})
-	assemblyOccurrences := make(map[string]struct{})
+	counts := CountOccurrencesByVector(occurrences)
+	return counts["init"], counts["global"], counts["exec"], counts["plugin"], counts["generate"], counts["test"], counts["unsafe"], counts["cgo"], counts["interface"], counts["reflect"], counts["constructor"], counts["assembly"]
+}
+
+// Counts unique occurrences for each attack vector, keyed by Occurrence.AttackVector.
+func CountOccurrencesByVector(occurrences []*Occurrence) map[string]int {
+	unique := make(map[string]map[string]struct{})
+	for _, occ := range occurrences {
+		if unique[occ.AttackVector] == nil {
+			unique[occ.AttackVector] = make(map[string]struct{})
+		}
+		unique[occ.AttackVector][occurrenceKey(occ)] = struct{}{}
+	}
+
+	counts := make(map[string]int)
+	for vector, keys := range unique {
+		counts[vector] = len(keys)
+	}
+	return counts
+}
 
+// Counts unique occurrences for each sub-kind of each attack vector, e.g. cgo directives
+// and includes. Occurrences without a kind are not counted.
+func CountOccurrencesByKind(occurrences []*Occurrence) map[string]map[string]int {
+	unique := make(map[string]map[string]struct{})
 	for _, occ := range occurrences {
-		switch occ.AttackVector {
-		case "init":
-			key := fmt.Sprintf("%s:%d", occ.FilePath, occ.LineNumber)
-			initOccurrences[key] = struct{}{}
-		case "global":
-			key := fmt.Sprintf("%s:%s:%d", occ.VariableName, occ.FilePath, occ.LineNumber)
-			globalVarOccurrences[key] = struct{}{}
-		case "exec":
-			key := fmt.Sprintf("%s:%s:%d", occ.MethodInvoked, occ.FilePath, occ.LineNumber)
-			execOccurrences[key] = struct{}{}
-		case "plugin":
-			key := fmt.Sprintf("%s:%s:%d", occ.FilePath, occ.MethodInvoked, occ.LineNumber)
-			pluginOccurrences[key] = struct{}{}
-		case "generate":
-			key := fmt.Sprintf("%s:%s:%d", occ.Command, occ.FilePath, occ.LineNumber)
-			goGenerateOccurrences[key] = struct{}{}
-		case "test":
-			key := fmt.Sprintf("%s:%s:%s:%d", occ.Command, occ.FilePath, occ.MethodInvoked, occ.LineNumber)
-			goTestOccurrences[key] = struct{}{}
-		case "unsafe":
-			key := fmt.Sprintf("%s:%s:%d", occ.MethodInvoked, occ.FilePath, occ.LineNumber) // TODO: which info to include here
-			unsafeOccurrences[key] = struct{}{}
-		case "cgo":
-			key := fmt.Sprintf("%s:%s:%d", occ.MethodInvoked, occ.FilePath, occ.LineNumber) // TODO: which info to include here
-			cgoOccurrences[key] = struct{}{}
-		case "interface":
-			key := fmt.Sprintf("%s:%s:%s:%d", occ.MethodInvoked, occ.TypePassed, occ.FilePath, occ.LineNumber)
-			interfaceOccurrences[key] = struct{}{}
-		case "reflect":
-			key := fmt.Sprintf("%s:%s:%d", occ.MethodInvoked, occ.FilePath, occ.LineNumber)
-			reflectOccurrences[key] = struct{}{}
-		case "constructor":
-			key := fmt.Sprintf("%s:%s:%d", occ.FilePath, occ.Pattern, occ.LineNumber)
-			constructorOccurrences[key] = struct{}{}
-		case "assembly":
-			key := fmt.Sprintf("%s:%s:%d", occ.MethodInvoked, occ.FilePath, occ.LineNumber)
-			assemblyOccurrences[key] = struct{}{}
+		if occ.Kind == "" {
+			continue
+		}
+		key := occ.AttackVector + "\x00" + occ.Kind
+		if unique[key] == nil {
+			unique[key] = make(map[string]struct{})
+		}
+		unique[key][occurrenceKey(occ)] = struct{}{}
+	}
+
+	counts := make(map[string]map[string]int)
+	for key, keys := range unique {
+		vector, kind, _ := strings.Cut(key, "\x00")
+		if counts[vector] == nil {
+			counts[vector] = make(map[string]int)
 		}
+		counts[vector][kind] = len(keys)
 	}
+	return counts
+}
 
-	return len(initOccurrences), len(globalVarOccurrences), len(execOccurrences), len(pluginOccurrences), len(goGenerateOccurrences), len(goTestOccurrences), len(unsafeOccurrences), len(cgoOccurrences), len(interfaceOccurrences), len(reflectOccurrences), len(constructorOccurrences), len(assemblyOccurrences)
+// Gets the key identifying an occurrence when counting unique occurrences.
+func occurrenceKey(occ *Occurrence) string {
+	switch occ.AttackVector {
+	case "init":
+		return fmt.Sprintf("%s:%d", occ.FilePath, occ.LineNumber)
+	case "global":
+		return fmt.Sprintf("%s:%s:%d", occ.VariableName, occ.FilePath, occ.LineNumber)
+	case "plugin":
+		return fmt.Sprintf("%s:%s:%d", occ.FilePath, occ.MethodInvoked, occ.LineNumber)
+	case "generate":
+		return fmt.Sprintf("%s:%s:%d", occ.Command, occ.FilePath, occ.LineNumber)
+	case "test":
+		return fmt.Sprintf("%s:%s:%s:%d", occ.Command, occ.FilePath, occ.MethodInvoked, occ.LineNumber)
+	case "interface":
+		return fmt.Sprintf("%s:%s:%s:%d", occ.MethodInvoked, occ.TypePassed, occ.FilePath, occ.LineNumber)
+	case "constructor":
+		return fmt.Sprintf("%s:%s:%d", occ.FilePath, occ.Pattern, occ.LineNumber)
+	case "environment":
+		return fmt.Sprintf("%s:%s:%s:%d", occ.MethodInvoked, strings.Join(occ.Literals, ","), occ.FilePath, occ.LineNumber)
+	case "embed", "obfuscated":
+		return fmt.Sprintf("%s:%s:%s:%d", occ.MethodInvoked, strings.Join(occ.Literals, ","), occ.FilePath, occ.LineNumber)
+	default: // exec, unsafe, cgo, reflect, assembly
+		return fmt.Sprintf("%s:%s:%d", occ.MethodInvoked, occ.FilePath, occ.LineNumber) // TODO: which info to include here
+	}
+}
+
+func toOccurrenceJSON(occ *Occurrence) OccurrenceJSON {
+	return OccurrenceJSON{
+		PackageName:     occ.PackageName,
+		Type:            occ.AttackVector,
+		FilePath:        occ.FilePath,
+		LineNumber:      occ.LineNumber,
+		ColumnNumber:    occ.ColumnNumber,
+		MethodInvoked:   occ.MethodInvoked,
+		TypePassed:      occ.TypePassed,
+		VariableName:    occ.VariableName,
+		Command:         occ.Command,
+		Pattern:         occ.Pattern,
+		TargetPackage:   occ.TargetPackage,
+		Kind:            occ.Kind,
+		Literals:        occ.Literals,
+		Severity:        occ.Severity,
+		BuildConstraint: occ.BuildConstraint,
+		UnusualTags:     occ.UnusualTags,
+		UnsafeImported:  occ.UnsafeImported,
+		ModulePath:      occ.ModulePath,
+		ModuleVersion:   occ.ModuleVersion,
+		ImportPath:      occ.ImportPath,
+		Reachable:       occ.Reachable,
+		Function:        occ.Function,
+		Snippet:         occ.Snippet,
+		CallChain:       occ.CallChain,
+		CallPath:        occ.CallPath,
+		Fingerprint:     Fingerprint(occ),
+	}
 }
 
 func PrintOccurrences(occurrences []*Occurrence) {
 	var result []OccurrenceJSON
 	for _, occ := range occurrences {
-		occJSON := OccurrenceJSON{
-			PackageName:   occ.PackageName,
-			Type:          occ.AttackVector,
-			FilePath:      occ.FilePath,
-			LineNumber:    occ.LineNumber,
-			MethodInvoked: occ.MethodInvoked,
-			TypePassed:    occ.TypePassed,
-			VariableName:  occ.VariableName,
-			Command:       occ.Command,
-			Pattern:       occ.Pattern,
-		}
-		result = append(result, occJSON)
+		result = append(result, toOccurrenceJSON(occ))
 	}
 
 	jsonData, err := json.MarshalIndent(result, "", "  ")
@@ -326,8 +410,207 @@ func PrintOccurrences(occurrences []*Occurrence) {
 	fmt.Println(string(jsonData))
 }
 
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      sarifMessage       `json:"fullDescription"`
+	Help                 sarifMessage       `json:"help"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string           `json:"ruleId"`
+	RuleIndex  int              `json:"ruleIndex"`
+	Level      string           `json:"level"`
+	Message    sarifMessage     `json:"message"`
+	Locations  []sarifLocation  `json:"locations"`
+	Properties *sarifProperties `json:"properties,omitempty"`
+}
+
+// Call graph information of a result, see CallGraph.
+type sarifProperties struct {
+	CallChain []string `json:"callChain,omitempty"`
+	CallPath  []string `json:"callPath,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"` // nil for binary files
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// Writes the occurrences as a SARIF 2.1.0 log. Every attack vector is a rule and every
+// occurrence a result. Files under rootPath are reported relative to %SRCROOT%.
+func WriteSARIF(w io.Writer, rootPath string, occurrences []*Occurrence) error {
+	driver := sarifDriver{
+		Name:           ToolName,
+		Version:        ToolVersion,
+		InformationURI: "https://github.com/chains-project/GoSurf",
+	}
+	ruleIndex := make(map[string]int)
+	for idx, vector := range AttackVectors {
+		ruleIndex[vector.Name] = idx
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:                   vector.ID,
+			Name:                 sarifRuleName(vector.Title),
+			ShortDescription:     sarifMessage{Text: vector.Title},
+			FullDescription:      sarifMessage{Text: vector.Description},
+			Help:                 sarifMessage{Text: vector.Help},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(vector.Severity)},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(occurrences))
+	for _, occ := range occurrences {
+		idx, ok := ruleIndex[occ.AttackVector]
+		if !ok {
+			continue
+		}
+		vector := AttackVectors[idx]
+
+		artifact := sarifArtifactLocation{URI: fileURI(occ.FilePath)}
+		if rel, err := filepath.Rel(rootPath, occ.FilePath); err == nil && !strings.HasPrefix(rel, "..") {
+			artifact = sarifArtifactLocation{URI: filepath.ToSlash(rel), URIBaseID: "%SRCROOT%"}
+		}
+
+		var region *sarifRegion
+		if occ.LineNumber > 0 {
+			region = &sarifRegion{StartLine: occ.LineNumber, StartColumn: occ.ColumnNumber}
+		}
+
+		var properties *sarifProperties
+		if len(occ.CallChain) > 0 || len(occ.CallPath) > 0 {
+			properties = &sarifProperties{CallChain: occ.CallChain, CallPath: occ.CallPath}
+		}
+
+		results = append(results, sarifResult{
+			RuleID:    vector.ID,
+			RuleIndex: idx,
+			Level:     sarifLevel(OccurrenceSeverity(occ)),
+			Message:   sarifMessage{Text: describeOccurrence(vector, occ)},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: artifact,
+					Region:           region,
+				},
+			}},
+			Properties: properties,
+		})
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(log)
+}
+
+// Gets the file URI of a path, made absolute first since file URIs cannot be relative,
+// e.g. file:///home/user/go/pkg/mod/example.com/m@v1.0.0/main.go.
+func fileURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// e.g. C:/Users on Windows
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// Gets a rule name in PascalCase from a title, e.g. "init() Functions" becomes "InitFunctions".
+func sarifRuleName(title string) string {
+	var sb strings.Builder
+	for _, word := range strings.FieldsFunc(title, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		sb.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return sb.String()
+}
+
+func sarifLevel(severity string) string {
+	switch severity {
+	case "high":
+		return "error"
+	case "medium":
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// Gets a one line description of the occurrence, e.g. "External Execution: exec.Command in package foo".
+func describeOccurrence(vector AttackVector, occ *Occurrence) string {
+	var details []string
+	for _, detail := range []string{occ.MethodInvoked, occ.Command, occ.VariableName, occ.Pattern} {
+		if detail != "" {
+			details = append(details, detail)
+		}
+	}
+	text := vector.Title
+	if len(details) > 0 {
+		text += ": " + strings.Join(details, ", ")
+	}
+	if occ.ModulePath != "" && occ.ModuleVersion != "" {
+		return fmt.Sprintf("%s in package %s (%s@%s)", text, occ.PackageName, occ.ModulePath, occ.ModuleVersion)
+	}
+	return fmt.Sprintf("%s in package %s", text, occ.PackageName)
+}
+
 func PrintDependencies(dependencies []Dependency) {
 	for _, dep := range dependencies {
+		if dep.ModulePath != "" {
+			fmt.Printf("%s (%s@%s)\n", dep.Path, dep.ModulePath, dep.ModuleVersion)
+			continue
+		}
 		fmt.Println(dep.Path)
 	}
 }
@@ -337,5 +620,5 @@ func updateProgressBar(current, total int) {
 	width := 50 // Width of the progress bar
 	progress := float64(current) / float64(total)
 	hashes := int(progress * float64(width))
-	fmt.Printf("\r[%-*s] %.2f%%", width, strings.Repeat("#", hashes), progress*100)
+	fmt.Fprintf(os.Stderr, "\r[%-*s] %.2f%%", width, strings.Repeat("#", hashes), progress*100)
 }
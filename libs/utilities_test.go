@@ -0,0 +1,36 @@
+package libs
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteSARIFLocations(t *testing.T) {
+	occurrences := []*Occurrence{
+		{AttackVector: "exec", FilePath: filepath.Join("m", "main.go"), LineNumber: 3},
+		{AttackVector: "exec", FilePath: filepath.Join("dep", "dep.go"), LineNumber: 5},
+		{AttackVector: "exec", FilePath: filepath.Join("..", "other dir", "x.go"), LineNumber: 7},
+	}
+	var out bytes.Buffer
+	if err := WriteSARIF(&out, "m"+string(filepath.Separator), occurrences); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	results := log.Runs[0].Results
+	if artifact := results[0].Locations[0].PhysicalLocation.ArtifactLocation; artifact.URI != "main.go" || artifact.URIBaseID != "%SRCROOT%" {
+		t.Errorf("file under the root: got %+v", artifact)
+	}
+	for _, result := range results[1:] {
+		uri := result.Locations[0].PhysicalLocation.ArtifactLocation.URI
+		if !strings.HasPrefix(uri, "file:///") || strings.Contains(uri, "..") || strings.Contains(uri, " ") {
+			t.Errorf("file outside of the root: got invalid URI %s", uri)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package libs
+
+type AttackVector struct {
+	ID          string // identifier used in reports, e.g. P1
+	Name        string // value of Occurrence.AttackVector
+	Title       string
+	Severity    string // default severity of occurrences: low, medium or high
+	Description string
+	Help        string
+}
+
+// Attack vectors in report order: pre-build (P), init time (I), execution time (E) and
+// deceptive source code (S).
+var AttackVectors = []AttackVector{
+	{
+		ID: "P1", Name: "generate", Title: "Static Code Generation", Severity: "medium",
+		Description: "A go:generate directive runs an arbitrary command when go generate is invoked on the package.",
+		Help:        "Review the command of the go:generate directive and the generator it runs before executing go generate.",
+	},
+	{
+		ID: "P2", Name: "test", Title: "Testing Functions", Severity: "low",
+		Description: "Test, benchmark, example and fuzz functions run arbitrary code when go test is invoked on the package.",
+		Help:        "Review the test functions of dependencies before running their tests, e.g. in smoke tests of upgrades.",
+	},
+	{
+		ID: "I1", Name: "global", Title: "Global Variable Initialization", Severity: "medium",
+		Description: "A global variable initialized by a function call runs code as soon as the package is imported.",
+		Help:        "Check that the function initializing the global variable has no side effects beyond computing its value.",
+	},
+	{
+		ID: "I2", Name: "init", Title: "init() Functions", Severity: "medium",
+		Description: "An init() function runs code as soon as the package is imported, before main.",
+		Help:        "Check that the init() function only initializes package state and does not execute commands, access the network or write files.",
+	},
+	// Kind: the capability, exec, network, fs-write, plugin or unsafe.
+	{
+		ID: "I3", Name: "importtime", Title: "Import-Time Execution", Severity: "high",
+		Description: "A sensitive operation (exec, network, file write, plugin or unsafe) is reachable from an init() function or a global variable initializer, so it can run as soon as the package is imported.",
+		Help:        "Follow the call chain of the occurrence from the initializer to the operation, and check why it has to run at import time.",
+	},
+	{
+		ID: "E1", Name: "constructor", Title: "Constructor Methods", Severity: "low",
+		Description: "A factory (New...) function is a frequently called place where malicious code can be hidden.",
+		Help:        "Review the constructor functions of dependencies that are called by the module.",
+	},
+	{
+		ID: "E2", Name: "reflect", Title: "Reflection", Severity: "low",
+		Description: "The reflect package allows dynamic inspection and invocation of code, which hides behavior from static analysis.",
+		Help:        "Check which values are inspected or invoked through reflection and whether they can be controlled by an attacker.",
+	},
+	{
+		ID: "E3", Name: "interface", Title: "Interfaces", Severity: "low", // TODO: define better
+		Description: "A method invoked through an interface is dispatched at run time, so its implementation cannot be known statically.",
+		Help:        "Review the implementations of the interface that can reach this call site.",
+	},
+	{
+		ID: "E4", Name: "unsafe", Title: "Unsafe Pointers", Severity: "high",
+		Description: "unsafe.Pointer bypasses the type system and memory safety, e.g. to overwrite function pointers or read out of bounds.",
+		Help:        "Check that the conversion follows the unsafe.Pointer rules and cannot be used to redirect execution.",
+	},
+	// Kind: call, directive, include, constructor or exec. Literals: flags of #cgo directives and included headers.
+	{
+		ID: "E5", Name: "cgo", Title: "CGO Functions", Severity: "high",
+		Description: "A call into C code through cgo runs native code outside of the Go memory safety guarantees.",
+		Help:        "Review the C code in the cgo preamble and the native libraries linked by the package.",
+	},
+	// Kind: call, or syscall for system call instructions in assembly.
+	{
+		ID: "E6", Name: "assembly", Title: "Assembly Functions", Severity: "high", // TODO: define better
+		Description: "A call to a function implemented in an assembly file of the package runs code that is not visible in Go source.",
+		Help:        "Review the assembly implementation of the function.",
+	},
+	{
+		ID: "E9", Name: "linkname", Title: "Linkname Directives", Severity: "high",
+		Description: "A go:linkname directive binds a local symbol to an unexported symbol of another package, e.g. of the runtime, bypassing Go's visibility rules.",
+		Help:        "Check which symbol is linked and whether the package relies on internals of the runtime or the standard library.",
+	},
+	{
+		ID: "E7", Name: "plugin", Title: "Dynamic Plugins", Severity: "high",
+		Description: "plugin.Open loads and runs a pre-built shared object at run time.",
+		Help:        "Check where the plugin is loaded from and whether its path can be controlled by an attacker.",
+	},
+	{
+		ID: "E8", Name: "exec", Title: "External Execution", Severity: "high",
+		Description: "The package starts an external process, which can run arbitrary commands or pre-built binaries.",
+		Help:        "Check which command is executed and whether its arguments can be controlled by an attacker.",
+	},
+	// Kind: the kind of connection, e.g. dial, listen, http-client or socket. Literals: constant hosts and URLs.
+	{
+		ID: "E10", Name: "network", Title: "Network Access", Severity: "medium",
+		Description: "The package opens network connections, listens for them or creates raw sockets, which can exfiltrate data or download payloads.",
+		Help:        "Check which hosts the package connects to and whether the connection is expected for its functionality.",
+	},
+	// Kind: write or delete. Literals: constant paths.
+	{
+		ID: "E11", Name: "filesystem", Title: "Filesystem Writes", Severity: "medium",
+		Description: "The package writes, moves, deletes or changes the permissions of files, which can be used to persist on a machine or tamper with it.",
+		Help:        "Check which paths are modified and whether they can be controlled by an attacker.",
+	},
+	// Kind: read, write, list, or secret-path for credential files. Literals: variable names and credential paths.
+	{
+		ID: "E12", Name: "environment", Title: "Environment and Credentials", Severity: "medium",
+		Description: "The package reads or writes environment variables or accesses well-known credential files, a classic source of exfiltrated secrets.",
+		Help:        "Check which variables and files are accessed and where their values are sent. Secrets such as tokens, keys and SSH or cloud credentials are high severity.",
+	},
+	// Kind: syscall, mmap, mprotect or exec-memory. Literals: the system call number.
+	{
+		ID: "E13", Name: "syscall", Title: "Raw System Calls", Severity: "high",
+		Description: "The package invokes system calls directly or maps memory, which bypasses the abstractions of the standard library and can map executable memory.",
+		Help:        "Check which system call is invoked. Memory mapped or protected with PROT_EXEC (kind exec-memory) can run injected machine code.",
+	},
+	// Literals: the SHA-256 hash of the object.
+	{
+		ID: "E14", Name: "object", Title: "Prebuilt Object Files", Severity: "high",
+		Description: "A .syso object file is linked into the package as it is, so its machine code cannot be reviewed from source.",
+		Help:        "Check where the object comes from and rebuild it from source if possible. Its hash is recorded in the occurrence.",
+	},
+	// Kind: elf, mach-o, pe, wasm, shell, archive or data, from the magic bytes, or flow. Literals: the embedded file
+	// and its SHA-256 hash, or nothing for flows.
+	{
+		ID: "E15", Name: "embed", Title: "Embedded Files", Severity: "medium",
+		Description: "A //go:embed directive ships files of the package directory inside the binary. Executables, shared objects, scripts and archives can hide a payload that is later run or written to disk.",
+		Help:        "Check the kind of the embedded file (from its magic bytes) and whether the variable flows into exec, os.WriteFile or plugin (kind flow).",
+	},
+	// Kind: bidi, invisible, confusable or mixed-script. Literals: the context of the character (comment, string or
+	// code), the ASCII look-alike or the scripts.
+	{
+		ID: "S1", Name: "trojan", Title: "Trojan Source", Severity: "high",
+		Description: "Bidirectional control or invisible characters, or identifiers confusable with ASCII ones, make the source display differently than it compiles.",
+		Help:        "Inspect the raw bytes of the line, e.g. with a hex viewer. Bidi controls in comments and strings can hide code, and confusable identifiers can shadow trusted functions.",
+	},
+	// Kind: the chain of encodings, e.g. base64+gzip, or flow. Literals: what the payload contains (url, shell, elf or
+	// high-entropy), an excerpt and the entropy of the decoded data, or the decoding function for flows.
+	{
+		ID: "S2", Name: "obfuscated", Title: "Obfuscated Payloads", Severity: "high",
+		Description: "A string or byte literal hides a URL, a shell command or an ELF binary behind an encoding (base64, hex, gzip or XOR), or a literal is decoded at run time and then executed or written to a file.",
+		Help:        "Decode the literal (the decoded excerpt is recorded in the occurrence) and check what it downloads, runs or writes. Legitimate code rarely needs to hide such values.",
+	},
+}
+
+// Gets the severity of an occurrence: its own severity if set, otherwise the one of its attack vector.
+func OccurrenceSeverity(occ *Occurrence) string {
+	if occ.Severity != "" {
+		return occ.Severity
+	}
+	vector, _ := GetAttackVector(occ.AttackVector)
+	return vector.Severity
+}
+
+// Gets the attack vector with the given name.
+func GetAttackVector(name string) (AttackVector, bool) {
+	for _, vector := range AttackVectors {
+		if vector.Name == name {
+			return vector, true
+		}
+	}
+	return AttackVector{}, false
+}